@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConnect(t *testing.T) {
+	db, closeDB := NewSQLite(":memory:")
+	defer closeDB()
+
+	if err := Connect(context.Background(), db, 1, time.Millisecond, time.Second); err != nil {
+		t.Errorf("Expected SQLite to answer, got %v", err)
+	}
+}
+
+func TestConnectGivesUp(t *testing.T) {
+	// Nothing listens on port 1, so every attempt is refused.
+	db, err := sql.Open("postgres", "postgres://user@127.0.0.1:1/app?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := time.Now()
+	err = Connect(context.Background(), db, 3, 10*time.Millisecond, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "3 attempts") {
+		t.Fatalf("Expected Connect to give up after 3 attempts, got %v", err)
+	}
+	if waited := time.Since(start); waited < 30*time.Millisecond {
+		t.Errorf("Expected backoffs of 10ms and 20ms between attempts, waited %s", waited)
+	}
+}
+
+func TestConnectStopsOnCancel(t *testing.T) {
+	db, err := sql.Open("postgres", "postgres://user@127.0.0.1:1/app?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := Connect(ctx, db, 100, time.Second, time.Second); err != context.DeadlineExceeded {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/config"
+)
+
+// Replicas spreads reads round-robin over read replicas, skipping replicas
+// that failed their last health check and falling back to the primary when
+// none is left. Writes always go to the primary.
+type Replicas struct {
+	primary      *sql.DB
+	replicas     []*replica
+	next         atomic.Uint64
+	timeout      time.Duration
+	maxStaleness time.Duration
+
+	// lag reports how far a replica is behind the primary.
+	lag func(ctx context.Context, db *sql.DB) (time.Duration, error)
+}
+
+type replica struct {
+	name string
+	db   *sql.DB
+
+	healthy atomic.Bool
+	// fresh is set when the replica is healthy and within maxStaleness.
+	fresh atomic.Bool
+}
+
+// OpenReplicas connects to the configured replicas of primary and checks
+// them once before returning. Call Run to keep checking them.
+func OpenReplicas(ctx context.Context, primary *sql.DB, cfg config.Database) (*Replicas, func()) {
+	dbs := make([]*sql.DB, len(cfg.Replicas.URLs))
+	names := make([]string, len(cfg.Replicas.URLs))
+	for i, u := range cfg.Replicas.URLs {
+		dbs[i], _ = NewPostgres(u)
+		configurePool(dbs[i], cfg.Pool)
+		names[i] = replicaName(u)
+	}
+
+	r := newReplicas(primary, dbs, names, cfg.ConnectTimeout, cfg.Replicas.MaxStaleness)
+	r.Check(ctx)
+
+	close := func() {
+		for _, db := range dbs {
+			_ = db.Close()
+		}
+	}
+	return r, close
+}
+
+func newReplicas(primary *sql.DB, dbs []*sql.DB, names []string, timeout, maxStaleness time.Duration) *Replicas {
+	r := &Replicas{primary: primary, timeout: timeout, maxStaleness: maxStaleness, lag: postgresLag}
+	for i, db := range dbs {
+		r.replicas = append(r.replicas, &replica{name: names[i], db: db})
+	}
+	return r
+}
+
+// replicaName identifies a replica in logs without its credentials.
+func replicaName(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return "replica"
+	}
+	return parsed.Host
+}
+
+// Reader returns the database for a read that may be served by any healthy
+// replica.
+func (r *Replicas) Reader() *sql.DB {
+	return r.pick(func(rep *replica) bool { return rep.healthy.Load() })
+}
+
+// ListReader returns the database for listing skills. Only replicas within
+// the maximum staleness qualify, as missing or outdated entries are most
+// visible in lists.
+func (r *Replicas) ListReader() *sql.DB {
+	return r.pick(func(rep *replica) bool { return rep.fresh.Load() })
+}
+
+func (r *Replicas) pick(ok func(rep *replica) bool) *sql.DB {
+	n := uint64(len(r.replicas))
+	if n == 0 {
+		return r.primary
+	}
+
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if rep := r.replicas[(start+i)%n]; ok(rep) {
+			return rep.db
+		}
+	}
+	return r.primary
+}
+
+// Run checks the replicas every interval until ctx is cancelled.
+func (r *Replicas) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.Check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check pings every replica and, when a maximum staleness is set, measures
+// its replication lag.
+func (r *Replicas) Check(ctx context.Context) {
+	for _, rep := range r.replicas {
+		healthy, fresh := r.check(ctx, rep)
+
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				slog.InfoContext(ctx, "Read replica is healthy", "replica", rep.name)
+			} else {
+				slog.WarnContext(ctx, "Read replica is unhealthy, skipping it", "replica", rep.name)
+			}
+		}
+		if rep.fresh.Swap(fresh) != fresh && healthy {
+			if fresh {
+				slog.InfoContext(ctx, "Read replica caught up, using it for lists again", "replica", rep.name)
+			} else {
+				slog.WarnContext(ctx, "Read replica lags too far behind, skipping it for lists", "replica", rep.name, "max_staleness", r.maxStaleness)
+			}
+		}
+	}
+}
+
+func (r *Replicas) check(ctx context.Context, rep *replica) (healthy, fresh bool) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if err := rep.db.PingContext(ctx); err != nil {
+		return false, false
+	}
+	if r.maxStaleness == 0 {
+		return true, true
+	}
+
+	lag, err := r.lag(ctx, rep.db)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to measure replication lag", "replica", rep.name, "error", err)
+		return true, false
+	}
+	return true, lag <= r.maxStaleness
+}
+
+// postgresLag measures how long ago the replica replayed its latest
+// transaction. A replica that has replayed everything it received is not
+// lagging, however long ago the primary last wrote.
+func postgresLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	q := `SELECT CASE
+		WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+	END`
+
+	var seconds sql.NullFloat64
+	if err := db.QueryRowContext(ctx, q).Scan(&seconds); err != nil {
+		return 0, err
+	}
+	if !seconds.Valid {
+		return 0, errors.New("replica has not replayed any transaction yet")
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/narunart-atise/skill-api-kafka/logging"
+	"github.com/narunart-atise/skill-api-kafka/migrations"
+	"github.com/narunart-atise/skill-api-kafka/tracing"
+	_ "modernc.org/sqlite"
+)
+
+// NewSQLite opens the SQLite database at path, creating the schema if needed.
+// It is limited to one connection so writers don't trip over each other,
+// which also keeps a :memory: database alive for the life of the process.
+func NewSQLite(path string) (*sql.DB, func()) {
+	db, err := tracing.OpenDB("sqlite", path)
+	if err != nil {
+		logging.Fatal("Connect to database error", "error", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(migrations.SQLiteSchema); err != nil {
+		logging.Fatal("Create schema error", "error", err)
+	}
+
+	close := func() {
+		_ = db.Close()
+	}
+
+	return db, close
+}
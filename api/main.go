@@ -3,68 +3,157 @@ package main
 import (
 	"context"
 	"errors"
-	"fmt"
-	"log"
+	"expvar"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/narunart-atise/skill-api-kafka/api/database"
 	"github.com/narunart-atise/skill-api-kafka/api/skill"
+	"github.com/narunart-atise/skill-api-kafka/auth"
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/narunart-atise/skill-api-kafka/health"
+	"github.com/narunart-atise/skill-api-kafka/idempotency"
+	"github.com/narunart-atise/skill-api-kafka/logging"
+	"github.com/narunart-atise/skill-api-kafka/metrics"
+	"github.com/narunart-atise/skill-api-kafka/migrations"
+	"github.com/narunart-atise/skill-api-kafka/ratelimit"
+	"github.com/narunart-atise/skill-api-kafka/tracing"
+	"github.com/narunart-atise/skill-api-kafka/transport"
 )
 
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	db, closeDB := database.NewPostgres()
+	cfg, args, err := config.LoadAPI(os.Args[1:])
+	if err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	if err := logging.Setup(cfg.Log, "skill-api"); err != nil {
+		logging.Fatal("Failed to set up logging", "error", err)
+	}
+	slog.Info("Effective configuration", "config", config.Redacted(cfg))
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, "skill-api")
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	db, closeDB := database.Open(ctx, cfg.Database)
+
+	if len(args) > 0 && args[0] == "migrate" {
+		defer closeDB()
+		if cfg.Database.Driver != "postgres" {
+			logging.Fatal("Migrate needs a postgres database, other drivers create their schema on startup", "driver", cfg.Database.Driver)
+		}
+		if err := migrations.Run(ctx, db, args[1:], os.Stdout); err != nil {
+			logging.Fatal("Migrate failed", "error", err)
+		}
+		return
+	}
+
+	if cfg.Database.StatsInterval > 0 {
+		go database.LogStats(ctx, db, cfg.Database.StatsInterval)
+	}
+	metrics.RegisterDatabase(db, "primary")
+
 	s := skill.NewStorage(db)
 
-	producer, err := skill.NewProducer()
+	if len(args) > 0 && args[0] == "seed" {
+		defer closeDB()
+		if err := runSeed(ctx, s, cfg, args[1:]); err != nil {
+			logging.Fatal("Seed failed", "error", err)
+		}
+		return
+	}
+
+	// Reads go to the replicas once the seed, which reads its own writes, is
+	// out of the way.
+	if len(cfg.Database.Replicas.URLs) > 0 {
+		replicas, closeReplicas := database.OpenReplicas(ctx, db, cfg.Database)
+		defer closeReplicas()
+		go replicas.Run(ctx, cfg.Database.Replicas.CheckInterval)
+		s = skill.NewReplicatedStorage(db, replicas)
+	}
+
+	if cfg.Transport.Backend == "kafka" && cfg.Kafka.Topics.Ensure {
+		if err := cfg.Kafka.EnsureTopics(); err != nil {
+			logging.Fatal("Kafka topics are not as expected", "error", err)
+		}
+	}
+
+	publisher, err := transport.NewPublisher(cfg.Transport, cfg.Kafka)
 	if err != nil {
-		log.Fatalf("Failed to create Kafka producer: %v", err)
+		logging.Fatal("Failed to create publisher", "backend", cfg.Transport.Backend, "error", err)
 	}
+	metrics.RegisterSarama(publisher, "producer")
+	producer := skill.NewProducer(metrics.Publisher(tracing.Publisher(logging.Publisher(publisher))))
 	defer producer.Close()
 
-	h := skill.NewHandler(s, producer)
+	h := skill.NewHandler(s, producer, cfg.CheckExistence)
+
+	authenticator, err := auth.New(ctx, cfg.Auth)
+	if err != nil {
+		logging.Fatal("Failed to set up authentication", "error", err)
+	}
+	if cfg.Auth.Anonymous {
+		slog.Warn("Requests without credentials are accepted as anonymous", "role", cfg.Auth.AnonymousRole)
+	}
+
+	var store ratelimit.Store = ratelimit.NewMemory()
+	if cfg.RateLimit.Store == "database" {
+		store = ratelimit.NewSQL(db)
+	}
+	limiter := ratelimit.New(cfg.RateLimit, store)
+	go limiter.Run(ctx)
+
+	var responses idempotency.Store = idempotency.NewMemory()
+	if cfg.Idempotency.Store == "database" {
+		responses = idempotency.NewSQL(db)
+	}
+	cache := idempotency.New(cfg.Idempotency, responses)
+	go cache.Run(ctx)
 
-	r := gin.Default()
+	checker := health.New(cfg.Health.Timeout)
+	checker.Add("database", health.Database(db))
+	checker.Add(cfg.Transport.Backend, health.Transport(publisher, 0))
 
-	skillRoute := r.Group("/api/v1/skills")
-	skillRoute.GET("", h.GetAllSkill)
-	skillRoute.GET(":key", h.GetSkillByKey)
-	skillRoute.POST("", h.CreateSkill)
-	skillRoute.PUT(":key", h.UpdateSkill)
-	skillRoute.PATCH(":key/actions/name", h.UpdateSkillName)
-	skillRoute.PATCH(":key/actions/description", h.UpdateSkillDescription)
-	skillRoute.PATCH(":key/actions/logo", h.UpdateSkillLogo)
-	skillRoute.PATCH(":key/actions/tags", h.UpdateSkillTag)
-	skillRoute.DELETE(":key", h.DeleteSkill)
+	r := gin.New()
+	r.Use(logging.Gin("/healthz", "/readyz", "/metrics"), gin.Recovery(), metrics.Gin(), tracing.Gin("skill-api"))
+	// Probes and metrics stay open, only the skill endpoints authenticate.
+	h.Routes(r.Group("", limiter.GinFailures(), auth.Gin(authenticator), limiter.Gin(), cache.Gin()))
+	r.GET("/healthz", gin.WrapF(health.Live))
+	r.GET("/readyz", gin.WrapF(checker.Ready))
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/debug/vars", gin.WrapH(expvar.Handler()))
 
 	srv := http.Server{
-		Addr:    ":" + os.Getenv("PORT"),
+		Addr:    ":" + cfg.HTTP.Port,
 		Handler: r,
 	}
 
 	go func() {
 		<-ctx.Done()
-		fmt.Println("shuttign down...")
+		slog.Info("Shutting down")
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
 		defer cancel()
 		defer closeDB()
 
 		if err := srv.Shutdown(ctx); err != nil {
 			if !errors.Is(err, http.ErrServerClosed) {
-				log.Println(err)
+				slog.Error("Shutdown failed", "error", err)
 			}
 		}
 	}()
 
-	if err := srv.ListenAndServe(); err != nil {
-		log.Println(err)
+	slog.Info("Listening", "addr", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Server failed", "error", err)
 	}
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+
+	"github.com/narunart-atise/skill-api-kafka/api/seed"
+	"github.com/narunart-atise/skill-api-kafka/api/skill"
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/narunart-atise/skill-api-kafka/transport"
+)
+
+type seedStorage interface {
+	FindSkillByKey(ctx context.Context, key string) (skill.Skill, error)
+	FindDeletedSkillByKey(ctx context.Context, key string) (skill.Skill, error)
+	UpsertSkill(ctx context.Context, s skill.Skill, actor string) (skill.Skill, error)
+}
+
+// runSeed loads a fixture set and upserts it, by default through the command
+// topic so the consumer records revisions like any other write.
+func runSeed(ctx context.Context, st seedStorage, cfg config.API, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	env := fs.String("env", "dev", "fixture set to load: dev, e2e or demo")
+	dir := fs.String("dir", "../fixtures", "directory holding one sub-directory per fixture set")
+	direct := fs.Bool("direct", false, "write to the database instead of publishing commands")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	skills, err := seed.Load(filepath.Join(*dir, *env))
+	if err != nil {
+		return err
+	}
+
+	if *direct {
+		return seed.Direct(ctx, st, skills)
+	}
+
+	publisher, err := transport.NewPublisher(cfg.Transport, cfg.Kafka)
+	if err != nil {
+		return err
+	}
+	producer := skill.NewProducer(publisher)
+	defer producer.Close()
+
+	_, err = seed.Publish(ctx, st, producer, skills)
+	return err
+}
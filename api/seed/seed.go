@@ -0,0 +1,140 @@
+// Package seed loads skill fixtures from YAML or JSON files and upserts them,
+// either by publishing commands for the consumer or by writing directly to
+// the database.
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/narunart-atise/skill-api-kafka/api/skill"
+	"github.com/narunart-atise/skill-api-kafka/validate"
+	"gopkg.in/yaml.v3"
+)
+
+// Actor is recorded as created_by/updated_by for seeded skills.
+const Actor = "seed"
+
+type fixture struct {
+	Skills []skill.Skill `json:"skills" yaml:"skills"`
+}
+
+// Load reads every .yaml, .yml and .json file in dir, in name order. A key
+// defined in more than one file takes the value from the last file. Every
+// skill must be valid as an Insert.
+func Load(dir string) ([]skill.Skill, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var skills []skill.Skill
+	index := map[string]int{}
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		var f fixture
+		if filepath.Ext(name) == ".json" {
+			err = json.Unmarshal(data, &f)
+		} else {
+			err = yaml.Unmarshal(data, &f)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("seed: %s: %w", name, err)
+		}
+
+		for _, s := range f.Skills {
+			if err := validate.Command("Insert", validate.Skill{Key: s.Key, Name: s.Name, Description: s.Description, Logo: s.Logo, Tags: s.Tags}); err != nil {
+				return nil, fmt.Errorf("seed: %s: %s: %w", name, s.Key, err)
+			}
+			if s.Tags == nil {
+				s.Tags = []string{}
+			}
+			if i, ok := index[s.Key]; ok {
+				skills[i] = s
+				continue
+			}
+			index[s.Key] = len(skills)
+			skills = append(skills, s)
+		}
+	}
+
+	return skills, nil
+}
+
+type finder interface {
+	FindSkillByKey(ctx context.Context, key string) (skill.Skill, error)
+	FindDeletedSkillByKey(ctx context.Context, key string) (skill.Skill, error)
+}
+
+type upserter interface {
+	UpsertSkill(ctx context.Context, s skill.Skill, actor string) (skill.Skill, error)
+}
+
+// Direct upserts skills straight into the database.
+func Direct(ctx context.Context, st upserter, skills []skill.Skill) error {
+	for _, s := range skills {
+		if _, err := st.UpsertSkill(ctx, s, Actor); err != nil {
+			return fmt.Errorf("seed: %s: %w", s.Key, err)
+		}
+	}
+
+	slog.InfoContext(ctx, "Seeded skills into the database", "skills", len(skills))
+	return nil
+}
+
+// Publish sends the commands that make each skill match its fixture through
+// the normal command topic. All commands share one event ID, which is
+// returned so the whole seed run can be reverted.
+func Publish(ctx context.Context, st finder, p skill.Publisher, skills []skill.Skill) (string, error) {
+	eventID := uuid.NewString()
+
+	for _, s := range skills {
+		s := s
+		messages := []skill.Message{{Action: "Update", Key: s.Key, EventID: eventID, Actor: Actor, Data: &s}}
+
+		if _, err := st.FindSkillByKey(ctx, s.Key); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return "", err
+			}
+
+			if _, err := st.FindDeletedSkillByKey(ctx, s.Key); err == nil {
+				messages = append([]skill.Message{{Action: "RestoreSkill", Key: s.Key, EventID: eventID, Actor: Actor}}, messages...)
+			} else if errors.Is(err, sql.ErrNoRows) {
+				messages[0].Action = "Insert"
+			} else {
+				return "", err
+			}
+		}
+
+		for _, m := range messages {
+			if err := p.Publish(ctx, m); err != nil {
+				return "", fmt.Errorf("seed: %s: %w", s.Key, err)
+			}
+		}
+	}
+
+	slog.InfoContext(ctx, "Published seed skills", "skills", len(skills), "event_id", eventID)
+	return eventID, nil
+}
@@ -0,0 +1,61 @@
+package seed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlFixture := `skills:
+  - key: go
+    name: Go
+    tags: [go, golang]
+  - key: figma
+    name: Figma
+`
+	jsonFixture := `{"skills": [{"key": "go", "name": "Golang", "tags": ["go"]}]}`
+
+	if err := os.WriteFile(filepath.Join(dir, "a.yaml"), []byte(yamlFixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.json"), []byte(jsonFixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	skills, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+
+	if len(skills) != 2 {
+		t.Fatalf("Expected 2 skills, got %d", len(skills))
+	}
+	if skills[0].Key != "go" || skills[0].Name != "Golang" {
+		t.Errorf("Expected go to be overridden by b.json, got %v", skills[0])
+	}
+	if skills[1].Tags == nil {
+		t.Error("Expected missing tags to load as an empty list")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "c.yaml"), []byte("skills:\n  - key: Go Lang\n    name: Go\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("Expected an invalid skill to be refused")
+	}
+}
+
+func TestLoadFixtureSets(t *testing.T) {
+	for _, env := range []string{"dev", "e2e", "demo"} {
+		skills, err := Load(filepath.Join("..", "..", "fixtures", env))
+		if err != nil {
+			t.Fatalf("Load %s error: %v", env, err)
+		}
+		if len(skills) == 0 {
+			t.Errorf("Expected skills in the %s fixture set", env)
+		}
+	}
+}
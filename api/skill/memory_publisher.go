@@ -0,0 +1,47 @@
+package skill
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPublisher records published messages instead of sending them, so
+// handlers can be exercised without a broker.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []Message
+
+	// Err, when set, is returned by Publish and nothing is recorded.
+	Err error
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, message Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Err != nil {
+		return p.Err
+	}
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+// Messages returns a copy of everything published so far, oldest first.
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Message(nil), p.messages...)
+}
+
+// Reset forgets the recorded messages.
+func (p *MemoryPublisher) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.messages = nil
+}
@@ -0,0 +1,255 @@
+package skill
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/memdb"
+)
+
+type memoryStorage struct {
+	db *memdb.DB
+}
+
+// NewMemoryStorage keeps skills in db instead of a database server. It
+// behaves like NewStorage, down to returning sql.ErrNoRows for missing skills.
+func NewMemoryStorage(db *memdb.DB) *memoryStorage {
+	return &memoryStorage{db}
+}
+
+func (f Filter) matches(row memdb.Skill) bool {
+	if (row.DeletedAt != nil) != f.Deleted {
+		return false
+	}
+	if f.CreatedBy != "" && row.CreatedBy != f.CreatedBy {
+		return false
+	}
+	if f.UpdatedBy != "" && row.UpdatedBy != f.UpdatedBy {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && row.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !row.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if !f.UpdatedAfter.IsZero() && row.UpdatedAt.Before(f.UpdatedAfter) {
+		return false
+	}
+	if !f.UpdatedBefore.IsZero() && !row.UpdatedAt.Before(f.UpdatedBefore) {
+		return false
+	}
+	return true
+}
+
+// less orders skills the way orderBy does: by the sort column, then by key.
+func (f Filter) less(a, b Skill) bool {
+	column := "key"
+	if SortColumns[f.Sort] {
+		column = f.Sort
+	}
+
+	var c int
+	switch column {
+	case "key":
+		c = strings.Compare(a.Key, b.Key)
+	case "name":
+		c = strings.Compare(a.Name, b.Name)
+	case "created_at":
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	case "updated_at":
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	}
+	if f.Desc {
+		c = -c
+	}
+	if c == 0 {
+		c = strings.Compare(a.Key, b.Key)
+	}
+	return c < 0
+}
+
+func (s memoryStorage) FindAllSkill(ctx context.Context, filter Filter) ([]Skill, error) {
+	var skills []Skill
+	err := s.db.View(func(tx memdb.Tx) error {
+		for _, row := range tx.Skills() {
+			if filter.matches(row) {
+				skills = append(skills, skillFromRow(row))
+			}
+		}
+		return nil
+	})
+
+	sort.SliceStable(skills, func(i, j int) bool { return filter.less(skills[i], skills[j]) })
+	return skills, err
+}
+
+func (s memoryStorage) FindSkillByKey(ctx context.Context, key string) (Skill, error) {
+	return s.findSkill(key, false)
+}
+
+func (s memoryStorage) FindDeletedSkillByKey(ctx context.Context, key string) (Skill, error) {
+	return s.findSkill(key, true)
+}
+
+func (s memoryStorage) findSkill(key string, deleted bool) (Skill, error) {
+	var skill Skill
+	err := s.db.View(func(tx memdb.Tx) error {
+		row, ok := tx.Skill(key)
+		if !ok || (row.DeletedAt != nil) != deleted {
+			return sql.ErrNoRows
+		}
+		skill = skillFromRow(row)
+		return nil
+	})
+	return skill, err
+}
+
+func (s memoryStorage) PostSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	err := s.db.Update(func(tx memdb.Tx) error {
+		return tx.InsertSkill(memdb.Skill{
+			Key:         skill.Key,
+			Name:        skill.Name,
+			Description: skill.Description,
+			Logo:        skill.Logo,
+			Tags:        skill.Tags,
+		}, actor)
+	})
+	if err != nil {
+		return Skill{}, err
+	}
+	return s.FindSkillByKey(ctx, skill.Key)
+}
+
+// edit applies change to the live skill with the given key and stamps it as
+// updated by actor. Like an UPDATE matching no rows, a missing skill is only
+// reported by the read that follows.
+func (s memoryStorage) edit(ctx context.Context, key, actor string, change func(row *memdb.Skill)) (Skill, error) {
+	_ = s.db.Update(func(tx memdb.Tx) error {
+		tx.EditSkill(key, actor, false, change)
+		return nil
+	})
+	return s.FindSkillByKey(ctx, key)
+}
+
+func (s memoryStorage) EditSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	return s.edit(ctx, skill.Key, actor, func(row *memdb.Skill) {
+		row.Name, row.Description, row.Logo, row.Tags = skill.Name, skill.Description, skill.Logo, skill.Tags
+	})
+}
+
+func (s memoryStorage) EditSkillName(ctx context.Context, key string, name string, actor string) (Skill, error) {
+	return s.edit(ctx, key, actor, func(row *memdb.Skill) { row.Name = name })
+}
+
+func (s memoryStorage) EditSkillDescription(ctx context.Context, key, description string, actor string) (Skill, error) {
+	return s.edit(ctx, key, actor, func(row *memdb.Skill) { row.Description = description })
+}
+
+func (s memoryStorage) EditSkillLogo(ctx context.Context, key, logo string, actor string) (Skill, error) {
+	return s.edit(ctx, key, actor, func(row *memdb.Skill) { row.Logo = logo })
+}
+
+func (s memoryStorage) EditSkillTags(ctx context.Context, key string, Tags []string, actor string) (Skill, error) {
+	return s.edit(ctx, key, actor, func(row *memdb.Skill) { row.Tags = Tags })
+}
+
+func (s memoryStorage) DeleteSkill(ctx context.Context, rowKey, actor string) string {
+	_, _ = s.edit(ctx, rowKey, actor, func(row *memdb.Skill) {
+		now := time.Now().UTC()
+		row.DeletedAt = &now
+	})
+	return "success"
+}
+
+// UpsertSkill inserts skill or overwrites the existing one with the same key,
+// restoring it from the trash if needed.
+func (s memoryStorage) UpsertSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	_ = s.db.Update(func(tx memdb.Tx) error {
+		now := time.Now().UTC()
+		row, ok := tx.Skill(skill.Key)
+		if !ok {
+			row = memdb.Skill{Key: skill.Key, CreatedAt: now, CreatedBy: actor}
+		}
+		row.Name, row.Description, row.Logo, row.Tags = skill.Name, skill.Description, skill.Logo, skill.Tags
+		row.DeletedAt = nil
+		row.UpdatedAt = now
+		row.UpdatedBy = actor
+		tx.PutSkill(row)
+		return nil
+	})
+	return s.FindSkillByKey(ctx, skill.Key)
+}
+
+func (s memoryStorage) FindRevisionsByKey(ctx context.Context, key string) ([]Revision, error) {
+	revisions := []Revision{}
+	err := s.db.View(func(tx memdb.Tx) error {
+		for _, row := range tx.Revisions(key) {
+			revisions = append(revisions, revisionFromRow(row))
+		}
+		return nil
+	})
+	return revisions, err
+}
+
+func (s memoryStorage) FindRevision(ctx context.Context, key string, rev int) (Revision, error) {
+	revisions, err := s.FindRevisionsByKey(ctx, key)
+	if err != nil {
+		return Revision{}, err
+	}
+	for _, r := range revisions {
+		if r.Rev == rev {
+			return r, nil
+		}
+	}
+	return Revision{}, sql.ErrNoRows
+}
+
+func (s memoryStorage) FindRevisionsByEvent(ctx context.Context, eventID string) ([]Revision, error) {
+	revisions := []Revision{}
+	err := s.db.View(func(tx memdb.Tx) error {
+		for _, row := range tx.AllRevisions() {
+			if row.EventID == eventID {
+				revisions = append(revisions, revisionFromRow(row))
+			}
+		}
+		return nil
+	})
+	return revisions, err
+}
+
+func skillFromRow(row memdb.Skill) Skill {
+	return Skill{
+		Key:         row.Key,
+		Name:        row.Name,
+		Description: row.Description,
+		Logo:        row.Logo,
+		Tags:        row.Tags,
+		DeletedAt:   row.DeletedAt,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+		CreatedBy:   row.CreatedBy,
+		UpdatedBy:   row.UpdatedBy,
+	}
+}
+
+func revisionFromRow(row memdb.Revision) Revision {
+	return Revision{
+		Key:     row.Key,
+		Rev:     row.Rev,
+		EventID: row.EventID,
+		Action:  row.Action,
+		Actor:   row.Actor,
+		Skill: Skill{
+			Key:         row.Key,
+			Name:        row.Name,
+			Description: row.Description,
+			Logo:        row.Logo,
+			Tags:        row.Tags,
+		},
+		CreatedAt: row.CreatedAt,
+	}
+}
@@ -1,113 +1,47 @@
 package skill
 
 import (
+	"context"
 	"encoding/json"
-	"log"
-	"os"
 
-	"github.com/IBM/sarama"
+	"github.com/narunart-atise/skill-api-kafka/transport"
 )
 
-var (
-	broker = os.Getenv("BROKER")
-	topic  = os.Getenv("TOPIC")
-)
-
-type Producer struct {
-	producer sarama.SyncProducer
+// Message is a skill command published to the topic and applied by the consumer.
+// Every message produced by one API request shares the same EventID, which the
+// consumer records in the skill's revision history.
+type Message struct {
+	Action  string `json:"action"`
+	Key     string `json:"key,omitempty"`
+	EventID string `json:"event_id"`
+	Actor   string `json:"actor"`
+	Data    *Skill `json:"data,omitempty"`
 }
 
-func NewProducer() (*Producer, error) {
-	config := sarama.NewConfig()
-	config.Producer.Return.Successes = true
-	config.Producer.Return.Errors = true
-	config.Producer.Partitioner = sarama.NewRoundRobinPartitioner
-	config.Producer.RequiredAcks = sarama.WaitForAll
-
-	producer, err := sarama.NewSyncProducer([]string{broker}, config)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Producer{producer: producer}, nil
+// Publisher sends skill commands to the consumer. Producer publishes to the
+// configured broker and MemoryPublisher keeps messages in memory for tests.
+type Publisher interface {
+	Publish(ctx context.Context, message Message) error
 }
 
-func (p *Producer) SendMessageWithAction(action string, skill Skill) error {
-	message := map[string]interface{}{
-		"action": action,
-		"data":   skill,
-	}
-	messageBytes, err := json.Marshal(message)
-	if err != nil {
-		return err
-	}
-
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.ByteEncoder(messageBytes),
-	}
-
-	partition, offset, err := p.producer.SendMessage(msg)
-	if err != nil {
-		log.Printf("FAILED to send message: %s\n", err)
-		return err
-	}
-
-	log.Printf("Message sent to partition %d at offset %d\n", partition, offset)
-	return nil
+// Producer publishes skill commands as JSON on the configured transport.
+type Producer struct {
+	publisher transport.Publisher
 }
 
-func (p *Producer) SendMessageWithKey(action, key string, skill Skill) error {
-	message := map[string]interface{}{
-		"action": action,
-		"key":    key,
-		"data":   skill,
-	}
-	messageBytes, err := json.Marshal(message)
-	if err != nil {
-		return err
-	}
-
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.ByteEncoder(messageBytes),
-	}
-
-	partition, offset, err := p.producer.SendMessage(msg)
-	if err != nil {
-		log.Printf("FAILED to send message: %s\n", err)
-		return err
-	}
-
-	log.Printf("Message sent to partition %d at offset %d\n", partition, offset)
-	return nil
+func NewProducer(publisher transport.Publisher) *Producer {
+	return &Producer{publisher: publisher}
 }
 
-func (p *Producer) SendMessageKey(action, key string) error {
-	message := map[string]interface{}{
-		"action": action,
-		"key":    key,
-	}
+func (p *Producer) Publish(ctx context.Context, message Message) error {
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
 		return err
 	}
 
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.ByteEncoder(messageBytes),
-	}
-
-	partition, offset, err := p.producer.SendMessage(msg)
-	if err != nil {
-		log.Printf("FAILED to send message: %s\n", err)
-		return err
-	}
-
-	log.Printf("Message sent to partition %d at offset %d\n", partition, offset)
-	return nil
+	return p.publisher.Publish(ctx, transport.Message{Key: message.Key, Value: messageBytes})
 }
 
 func (p *Producer) Close() error {
-	return p.producer.Close()
+	return p.publisher.Close()
 }
@@ -0,0 +1,203 @@
+package skill
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/narunart-atise/skill-api-kafka/auth"
+)
+
+func (h handler) GetSkillRevisions(c *gin.Context) {
+	key := c.Param("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, ResponseError{
+			Status:  "error",
+			Message: "key is required",
+		})
+		return
+	}
+
+	revisions, err := h.st.FindRevisionsByKey(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseError{
+			Status:  "error",
+			Message: "revision not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   revisions,
+	})
+}
+
+func (h handler) RevertSkill(c *gin.Context) {
+	key := c.Param("key")
+	rev, err := strconv.Atoi(c.Param("rev"))
+	if key == "" || err != nil {
+		c.JSON(http.StatusBadRequest, ResponseError{
+			Status:  "error",
+			Message: "key and numeric rev are required",
+		})
+		return
+	}
+
+	revision, err := h.st.FindRevision(c.Request.Context(), key, rev)
+	if err != nil {
+		c.JSON(http.StatusNotFound, ResponseError{
+			Status:  "error",
+			Message: "revision not found",
+		})
+		return
+	}
+
+	eventID := uuid.NewString()
+	messages, err := h.restoreMessages(c.Request.Context(), eventID, key, &revision)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseError{
+			Status:  "error",
+			Message: "Failed to read current skill",
+		})
+		return
+	}
+
+	// Restoring a state in which the skill did not exist deletes it, which
+	// takes the permission DELETE does.
+	for _, message := range messages {
+		if message.Action == "DeleteSkill" && !auth.Authorize(c, auth.Admin) {
+			return
+		}
+	}
+
+	if err := h.publish(c, messages...); err != nil {
+		publishFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"event_id": eventID,
+		"data":     messages,
+	})
+}
+
+// RevertEvent undoes every change recorded under an event ID by restoring each
+// affected skill to the revision it had before the event.
+func (h handler) RevertEvent(c *gin.Context) {
+	eventID := c.Param("id")
+	revisions, err := h.st.FindRevisionsByEvent(c.Request.Context(), eventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ResponseError{
+			Status:  "error",
+			Message: "revision not found",
+		})
+		return
+	}
+	if len(revisions) == 0 {
+		c.JSON(http.StatusNotFound, ResponseError{
+			Status:  "error",
+			Message: "event not found",
+		})
+		return
+	}
+
+	revertID := uuid.NewString()
+	messages := []Message{}
+	for i, r := range revisions {
+		// Revisions are ordered by key then rev, so only the earliest change
+		// of each key within the event matters.
+		if i > 0 && revisions[i-1].Key == r.Key {
+			continue
+		}
+
+		// Only an Insert as the first revision shows the skill did not exist
+		// before. A skill seeded or created before revisions were recorded
+		// has none, and its state before the event is unknown.
+		var previous *Revision
+		if r.Rev > 1 {
+			prev, err := h.st.FindRevision(c.Request.Context(), r.Key, r.Rev-1)
+			if errors.Is(err, sql.ErrNoRows) {
+				unknownState(c, r.Key)
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, ResponseError{
+					Status:  "error",
+					Message: "revision not found",
+				})
+				return
+			}
+			previous = &prev
+		} else if r.Action != "Insert" {
+			unknownState(c, r.Key)
+			return
+		}
+
+		restore, err := h.restoreMessages(c.Request.Context(), revertID, r.Key, previous)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ResponseError{
+				Status:  "error",
+				Message: "Failed to read current skill",
+			})
+			return
+		}
+		messages = append(messages, restore...)
+	}
+
+	if err := h.publish(c, messages...); err != nil {
+		publishFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"event_id": revertID,
+		"data":     messages,
+	})
+}
+
+// unknownState refuses to revert an event for a skill whose state before it
+// was never recorded.
+func unknownState(c *gin.Context, key string) {
+	c.JSON(http.StatusConflict, ResponseError{
+		Status:  "error",
+		Message: fmt.Sprintf("state of skill %s before the event is unknown", key),
+	})
+}
+
+// restoreMessages builds the commands that bring key back to the state
+// captured in rev. A nil rev, or one recording a delete, means the skill did
+// not exist. A skill sitting in the trash is restored before it is updated.
+func (h handler) restoreMessages(ctx context.Context, eventID, key string, rev *Revision) ([]Message, error) {
+	if rev == nil || rev.Action == "DeleteSkill" {
+		return []Message{{Action: "DeleteSkill", Key: key, EventID: eventID}}, nil
+	}
+
+	skill := rev.Skill
+	update := Message{Action: "Update", Key: key, EventID: eventID, Data: &skill}
+
+	_, err := h.st.FindSkillByKey(ctx, key)
+	if err == nil {
+		return []Message{update}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	_, err = h.st.FindDeletedSkillByKey(ctx, key)
+	if err == nil {
+		return []Message{{Action: "RestoreSkill", Key: key, EventID: eventID}, update}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return []Message{{Action: "Insert", Key: key, EventID: eventID, Data: &skill}}, nil
+}
@@ -0,0 +1,29 @@
+package skill
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/narunart-atise/skill-api-kafka/auth"
+)
+
+// Routes registers the skill and event endpoints on r, each behind the
+// permission it needs. r must authenticate callers with auth.Gin.
+func (h *handler) Routes(r gin.IRouter) {
+	read, write, admin := auth.Require(auth.Read), auth.Require(auth.Write), auth.Require(auth.Admin)
+
+	skillRoute := r.Group("/api/v1/skills")
+	skillRoute.GET("", read, h.GetAllSkill)
+	skillRoute.GET(":key", read, h.GetSkillByKey)
+	skillRoute.POST("", write, h.CreateSkill)
+	skillRoute.PUT(":key", write, h.UpdateSkill)
+	skillRoute.PATCH(":key/actions/name", write, h.UpdateSkillName)
+	skillRoute.PATCH(":key/actions/description", write, h.UpdateSkillDescription)
+	skillRoute.PATCH(":key/actions/logo", write, h.UpdateSkillLogo)
+	skillRoute.PATCH(":key/actions/tags", write, h.UpdateSkillTag)
+	skillRoute.DELETE(":key", admin, h.DeleteSkill)
+	skillRoute.POST(":key/restore", write, h.RestoreSkill)
+	skillRoute.GET(":key/revisions", read, h.GetSkillRevisions)
+	skillRoute.POST(":key/revisions/:rev/revert", write, h.RevertSkill)
+
+	eventRoute := r.Group("/api/v1/events")
+	eventRoute.POST(":id/revert", admin, h.RevertEvent)
+}
@@ -1,9 +1,26 @@
 package skill
 
+import "time"
+
 type Skill struct {
-	Key         string   `json:"key"`
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Logo        string   `json:"logo"`
-	Tags        []string `json:"tags"`
+	Key         string     `json:"key"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Logo        string     `json:"logo"`
+	Tags        []string   `json:"tags"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CreatedBy   string     `json:"created_by"`
+	UpdatedBy   string     `json:"updated_by"`
+}
+
+type Revision struct {
+	Key       string    `json:"key"`
+	Rev       int       `json:"rev"`
+	EventID   string    `json:"event_id"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	Skill     Skill     `json:"skill"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -1,18 +1,159 @@
 package skill
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/narunart-atise/skill-api-kafka/auth"
+	"github.com/narunart-atise/skill-api-kafka/validate"
 )
 
 type handler struct {
-	st       storager
-	producer *Producer
+	st        Storager
+	publisher Publisher
+	// checkExistence refuses commands for missing or existing skills before
+	// publishing them.
+	checkExistence bool
 }
 
-func NewHandler(st storager, producer *Producer) *handler {
-	return &handler{st: st, producer: producer}
+func NewHandler(st Storager, publisher Publisher, checkExistence bool) *handler {
+	return &handler{st: st, publisher: publisher, checkExistence: checkExistence}
+}
+
+// actor names the principal authenticated by auth.Gin. It is stamped on
+// every published message so the consumer can record who made each change.
+func actor(c *gin.Context) string {
+	if p, ok := auth.FromContext(c.Request.Context()); ok {
+		return p.Actor()
+	}
+	return auth.Anonymous.Actor()
+}
+
+// publish stamps messages with the caller's identity and sends them in order.
+// They are all validated first, so that an invalid one publishes nothing.
+func (h handler) publish(c *gin.Context, messages ...Message) error {
+	for _, message := range messages {
+		if err := validate.Command(message.Action, fields(message)); err != nil {
+			return err
+		}
+	}
+	for _, message := range messages {
+		message.Actor = actor(c)
+		if err := h.publisher.Publish(c.Request.Context(), message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// command publishes a command sent by a client once, unless turned off, the
+// current state of its skill allows it. publish validates it.
+func (h handler) command(c *gin.Context, message Message) error {
+	if h.checkExistence {
+		if err := h.check(c.Request.Context(), message.Action, message.Key); err != nil {
+			return err
+		}
+	}
+	return h.publish(c, message)
+}
+
+// stateError refuses a command the current state of its skill does not
+// allow.
+type stateError struct {
+	status  int
+	message string
+}
+
+func (e stateError) Error() string { return e.message }
+
+// check refuses action when key names no skill it can apply to: Insert needs
+// a free key, even of a deleted skill, RestoreSkill a deleted skill and the
+// other actions a live one. The state read may be stale, so the consumer
+// checks again; when it cannot be read the command is let through for the
+// consumer to decide.
+func (h handler) check(ctx context.Context, action, key string) error {
+	live, deleted, err := h.state(ctx, key)
+	if err != nil {
+		slog.ErrorContext(ctx, "Existence check failed, publishing the command unchecked", "key", key, "error", err)
+		return nil
+	}
+
+	switch action {
+	case "Insert":
+		if live {
+			return stateError{http.StatusConflict, "skill already exists"}
+		}
+		if deleted {
+			return stateError{http.StatusConflict, "skill is deleted, restore it instead"}
+		}
+	case "RestoreSkill":
+		if live {
+			return stateError{http.StatusConflict, "skill is not deleted"}
+		}
+		if !deleted {
+			return stateError{http.StatusNotFound, "skill not found"}
+		}
+	default:
+		if !live {
+			return stateError{http.StatusNotFound, "skill not found"}
+		}
+	}
+	return nil
+}
+
+// state tells whether key names a live skill, a deleted one or neither.
+func (h handler) state(ctx context.Context, key string) (live, deleted bool, err error) {
+	if _, err = h.st.FindSkillByKey(ctx, key); !errors.Is(err, sql.ErrNoRows) {
+		return err == nil, false, err
+	}
+	if _, err = h.st.FindDeletedSkillByKey(ctx, key); !errors.Is(err, sql.ErrNoRows) {
+		return false, err == nil, err
+	}
+	return false, false, nil
+}
+
+// fields picks what validate checks out of message.
+func fields(message Message) validate.Skill {
+	s := validate.Skill{Key: message.Key}
+	if message.Data != nil {
+		s.Name, s.Description, s.Logo, s.Tags = message.Data.Name, message.Data.Description, message.Data.Logo, message.Data.Tags
+	}
+	return s
+}
+
+// publishFailed answers a request whose messages were not published: with
+// 422 and the invalid fields when validation refused them, with 404 or 409
+// when the state of the skill did, or with 500.
+func publishFailed(c *gin.Context, err error) {
+	var refused stateError
+	if errors.As(err, &refused) {
+		c.JSON(refused.status, ResponseError{
+			Status:  "error",
+			Message: refused.message,
+		})
+		return
+	}
+	var invalid validate.Errors
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusUnprocessableEntity, ValidationError{
+			Status:  "error",
+			Message: "Skill is invalid",
+			Errors:  invalid,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, ResponseError{
+		Status:  "error",
+		Message: "Failed to send message to Kafka",
+	})
 }
 
 type ResponseError struct {
@@ -20,8 +161,24 @@ type ResponseError struct {
 	Message string `json:"message"`
 }
 
+// ValidationError lists what is wrong with each invalid field.
+type ValidationError struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Errors  validate.Errors `json:"errors"`
+}
+
 func (h handler) GetAllSkill(c *gin.Context) {
-	skills, err := h.st.FindAllSkill()
+	filter, err := parseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ResponseError{
+			Status:  "error",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	skills, err := h.st.FindAllSkill(c.Request.Context(), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ResponseError{
 			Status:  "error",
@@ -36,6 +193,51 @@ func (h handler) GetAllSkill(c *gin.Context) {
 	})
 }
 
+// parseFilter reads the list query string:
+// ?deleted=true&created_by=&updated_by=&created_after=&created_before=
+// &updated_after=&updated_before=&sort=updated_at&order=desc
+// Times are RFC 3339.
+func parseFilter(c *gin.Context) (Filter, error) {
+	var filter Filter
+	var err error
+
+	if deleted := c.Query("deleted"); deleted != "" {
+		if filter.Deleted, err = strconv.ParseBool(deleted); err != nil {
+			return Filter{}, errors.New("deleted must be true or false")
+		}
+	}
+
+	filter.CreatedBy = c.Query("created_by")
+	filter.UpdatedBy = c.Query("updated_by")
+
+	times := map[string]*time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+		"updated_after":  &filter.UpdatedAfter,
+		"updated_before": &filter.UpdatedBefore,
+	}
+	for name, t := range times {
+		if v := c.Query(name); v != "" {
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				return Filter{}, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+		}
+	}
+
+	if filter.Sort = c.Query("sort"); filter.Sort != "" && !SortColumns[filter.Sort] {
+		return Filter{}, fmt.Errorf("sort must be one of key, name, created_at, updated_at")
+	}
+	switch c.Query("order") {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return Filter{}, errors.New("order must be asc or desc")
+	}
+
+	return filter, nil
+}
+
 func (h handler) GetSkillByKey(c *gin.Context) {
 	key := c.Param("key")
 	if key == "" {
@@ -46,11 +248,18 @@ func (h handler) GetSkillByKey(c *gin.Context) {
 		return
 	}
 
-	getSkill, err := h.st.FindSkillByKey(key)
+	getSkill, err := h.st.FindSkillByKey(c.Request.Context(), key)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, ResponseError{
+			Status:  "error",
+			Message: "skill not found",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ResponseError{
 			Status:  "error",
-			Message: "skill not found",
+			Message: "Failed to read skill",
 		})
 		return
 	}
@@ -71,11 +280,8 @@ func (h handler) CreateSkill(c *gin.Context) {
 		return
 	}
 
-	if err := h.producer.SendMessageWithAction("Insert", skill); err != nil {
-		c.JSON(http.StatusInternalServerError, ResponseError{
-			Status:  "error",
-			Message: "Failed to send message to Kafka",
-		})
+	if err := h.command(c, Message{Action: "Insert", Key: skill.Key, EventID: uuid.NewString(), Data: &skill}); err != nil {
+		publishFailed(c, err)
 		return
 	}
 
@@ -106,11 +312,8 @@ func (h handler) UpdateSkill(c *gin.Context) {
 	}
 
 	skill.Key = key
-	if err := h.producer.SendMessageWithAction("Update", skill); err != nil {
-		c.JSON(http.StatusInternalServerError, ResponseError{
-			Status:  "error",
-			Message: "Failed to send message to Kafka",
-		})
+	if err := h.command(c, Message{Action: "Update", Key: key, EventID: uuid.NewString(), Data: &skill}); err != nil {
+		publishFailed(c, err)
 		return
 	}
 
@@ -132,18 +335,15 @@ func (h handler) UpdateSkillName(c *gin.Context) {
 
 	var skill Skill
 	if err := c.Bind(&skill); err != nil {
-		c.JSON(http.StatusInternalServerError, ResponseError{
+		c.JSON(http.StatusBadRequest, ResponseError{
 			Status:  "error",
 			Message: "Request payload is invalid",
 		})
 		return
 	}
 
-	if err := h.producer.SendMessageWithKey("UpdateName", key, skill); err != nil {
-		c.JSON(http.StatusInternalServerError, ResponseError{
-			Status:  "error",
-			Message: "Failed to send message to Kafka",
-		})
+	if err := h.command(c, Message{Action: "UpdateName", Key: key, EventID: uuid.NewString(), Data: &skill}); err != nil {
+		publishFailed(c, err)
 		return
 	}
 
@@ -172,11 +372,8 @@ func (h handler) UpdateSkillDescription(c *gin.Context) {
 		return
 	}
 
-	if err := h.producer.SendMessageWithKey("UpdateDescription", key, skill); err != nil {
-		c.JSON(http.StatusInternalServerError, ResponseError{
-			Status:  "error",
-			Message: "Failed to send message to Kafka",
-		})
+	if err := h.command(c, Message{Action: "UpdateDescription", Key: key, EventID: uuid.NewString(), Data: &skill}); err != nil {
+		publishFailed(c, err)
 		return
 	}
 
@@ -205,11 +402,8 @@ func (h handler) UpdateSkillLogo(c *gin.Context) {
 		return
 	}
 
-	if err := h.producer.SendMessageWithKey("UpdateLogo", key, skill); err != nil {
-		c.JSON(http.StatusInternalServerError, ResponseError{
-			Status:  "error",
-			Message: "Failed to send message to Kafka",
-		})
+	if err := h.command(c, Message{Action: "UpdateLogo", Key: key, EventID: uuid.NewString(), Data: &skill}); err != nil {
+		publishFailed(c, err)
 		return
 	}
 
@@ -237,11 +431,8 @@ func (h handler) UpdateSkillTag(c *gin.Context) {
 		return
 	}
 
-	if err := h.producer.SendMessageWithKey("UpdateTags", key, skill); err != nil {
-		c.JSON(http.StatusInternalServerError, ResponseError{
-			Status:  "error",
-			Message: "Failed to send message to Kafka",
-		})
+	if err := h.command(c, Message{Action: "UpdateTags", Key: key, EventID: uuid.NewString(), Data: &skill}); err != nil {
+		publishFailed(c, err)
 		return
 	}
 
@@ -262,16 +453,34 @@ func (h handler) DeleteSkill(c *gin.Context) {
 
 	}
 
-	if err := h.producer.SendMessageKey("DeleteSkill", key); err != nil {
-		c.JSON(http.StatusInternalServerError, ResponseError{
+	if err := h.command(c, Message{Action: "DeleteSkill", Key: key, EventID: uuid.NewString()}); err != nil {
+		publishFailed(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   "Skill deleted",
+	})
+}
+
+func (h handler) RestoreSkill(c *gin.Context) {
+	key := c.Param("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, ResponseError{
 			Status:  "error",
-			Message: "Failed to send message to Kafka",
+			Message: "key is required",
 		})
 		return
 	}
 
+	if err := h.command(c, Message{Action: "RestoreSkill", Key: key, EventID: uuid.NewString()}); err != nil {
+		publishFailed(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
-		"data":   "Skill deleted",
+		"data":   "Skill restored",
 	})
 }
@@ -0,0 +1,471 @@
+package skill
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/narunart-atise/skill-api-kafka/auth"
+	"github.com/narunart-atise/skill-api-kafka/config"
+)
+
+type handlerTest struct {
+	router    *gin.Engine
+	storage   *storage
+	publisher *MemoryPublisher
+}
+
+// setupHandlerTest serves the routes to an anonymous admin unless middleware
+// authenticates someone else. Existence checks are off, so commands can be
+// sent for any key.
+func setupHandlerTest(t *testing.T, middleware ...gin.HandlerFunc) handlerTest {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db := setupTestDB()
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`DELETE FROM skill; DELETE FROM skill_revision`); err != nil {
+		t.Fatalf("can't clear tables: %v", err)
+	}
+
+	ht := handlerTest{
+		router:    gin.New(),
+		storage:   NewStorage(db),
+		publisher: NewMemoryPublisher(),
+	}
+	if len(middleware) == 0 {
+		admin := auth.Anonymous
+		admin.Roles = []string{"admin"}
+		middleware = []gin.HandlerFunc{as(admin)}
+	}
+	ht.router.Use(middleware...)
+	NewHandler(ht.storage, ht.publisher, false).Routes(ht.router)
+	return ht
+}
+
+func as(p auth.Principal) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+	}
+}
+
+func (ht handlerTest) do(method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	ht.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// published returns the recorded messages with event IDs checked and cleared
+// so they can be compared directly.
+func (ht handlerTest) published(t *testing.T) []Message {
+	t.Helper()
+	messages := ht.publisher.Messages()
+	if len(messages) == 0 {
+		return messages
+	}
+	eventID := messages[0].EventID
+	if eventID == "" {
+		t.Error("Expected messages to have an event ID")
+	}
+	for i := range messages {
+		if messages[i].EventID != eventID {
+			t.Errorf("Expected one event ID per request, got %s and %s", eventID, messages[i].EventID)
+		}
+		messages[i].EventID = ""
+	}
+	return messages
+}
+
+func decodeData(t *testing.T, rec *httptest.ResponseRecorder, data any) {
+	t.Helper()
+	var body struct {
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("can't decode response %s: %v", rec.Body, err)
+	}
+	if body.Status != "success" {
+		t.Fatalf("Expected success, got %s", rec.Body)
+	}
+	if err := json.Unmarshal(body.Data, data); err != nil {
+		t.Fatalf("can't decode data %s: %v", body.Data, err)
+	}
+}
+
+func TestGetSkillHandlers(t *testing.T) {
+	ht := setupHandlerTest(t)
+	for _, key := range []string{"go", "rust"} {
+		if _, err := ht.storage.PostSkill(context.Background(), Skill{Key: key, Name: key, Tags: []string{"lang"}}, "tester"); err != nil {
+			t.Fatalf("PostSkill error: %v", err)
+		}
+	}
+
+	t.Run("GetAllSkill", func(t *testing.T) {
+		rec := ht.do(http.MethodGet, "/api/v1/skills?sort=key&order=desc", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		var skills []Skill
+		decodeData(t, rec, &skills)
+		if len(skills) != 2 || skills[0].Key != "rust" {
+			t.Errorf("Unexpected skills %v", skills)
+		}
+	})
+
+	t.Run("GetAllSkillBadFilter", func(t *testing.T) {
+		rec := ht.do(http.MethodGet, "/api/v1/skills?order=sideways", "")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("GetSkillByKey", func(t *testing.T) {
+		rec := ht.do(http.MethodGet, "/api/v1/skills/go", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		var skill Skill
+		decodeData(t, rec, &skill)
+		if skill.Key != "go" || skill.CreatedBy != "tester" {
+			t.Errorf("Unexpected skill %v", skill)
+		}
+	})
+
+	t.Run("GetSkillByKeyMissing", func(t *testing.T) {
+		rec := ht.do(http.MethodGet, "/api/v1/skills/cobol", "")
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", rec.Code)
+		}
+	})
+
+	if len(ht.publisher.Messages()) != 0 {
+		t.Errorf("Expected reads not to publish, got %v", ht.publisher.Messages())
+	}
+}
+
+func TestCommandHandlers(t *testing.T) {
+	body := `{"key":"go","name":"Go","description":"A language","logo":"https://example.com/go.png","tags":["lang"]}`
+	data := &Skill{Key: "go", Name: "Go", Description: "A language", Logo: "https://example.com/go.png", Tags: []string{"lang"}}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   Message
+	}{
+		{"CreateSkill", http.MethodPost, "/api/v1/skills", body, Message{Action: "Insert", Key: "go", Data: data}},
+		{"UpdateSkill", http.MethodPut, "/api/v1/skills/go", strings.Replace(body, `"key":"go"`, `"key":"ignored"`, 1), Message{Action: "Update", Key: "go", Data: data}},
+		{"UpdateSkillName", http.MethodPatch, "/api/v1/skills/go/actions/name", `{"name":"Go"}`, Message{Action: "UpdateName", Key: "go", Data: &Skill{Name: "Go"}}},
+		{"UpdateSkillDescription", http.MethodPatch, "/api/v1/skills/go/actions/description", `{"description":"A language"}`, Message{Action: "UpdateDescription", Key: "go", Data: &Skill{Description: "A language"}}},
+		{"UpdateSkillLogo", http.MethodPatch, "/api/v1/skills/go/actions/logo", `{"logo":"https://example.com/go.png"}`, Message{Action: "UpdateLogo", Key: "go", Data: &Skill{Logo: "https://example.com/go.png"}}},
+		{"UpdateSkillTag", http.MethodPatch, "/api/v1/skills/go/actions/tags", `{"tags":["lang"]}`, Message{Action: "UpdateTags", Key: "go", Data: &Skill{Tags: []string{"lang"}}}},
+		{"DeleteSkill", http.MethodDelete, "/api/v1/skills/go", "", Message{Action: "DeleteSkill", Key: "go"}},
+		{"RestoreSkill", http.MethodPost, "/api/v1/skills/go/restore", "", Message{Action: "RestoreSkill", Key: "go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ht := setupHandlerTest(t)
+
+			rec := ht.do(tt.method, tt.path, tt.body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+			}
+
+			tt.want.Actor = "anonymous"
+			got := ht.published(t)
+			if !reflect.DeepEqual(got, []Message{tt.want}) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+
+		t.Run(tt.name+"PublishError", func(t *testing.T) {
+			ht := setupHandlerTest(t)
+			ht.publisher.Err = errors.New("broker down")
+
+			rec := ht.do(tt.method, tt.path, tt.body)
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("Expected 500, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestCommandHandlersRejectBadPayload(t *testing.T) {
+	ht := setupHandlerTest(t)
+
+	rec := ht.do(http.MethodPost, "/api/v1/skills", `{"key":`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rec.Code)
+	}
+	if len(ht.publisher.Messages()) != 0 {
+		t.Errorf("Expected nothing published, got %v", ht.publisher.Messages())
+	}
+}
+
+func TestCommandHandlersRejectInvalidSkill(t *testing.T) {
+	for _, tc := range []struct {
+		method, path, body string
+		fields             []string
+	}{
+		{http.MethodPost, "/api/v1/skills", `{"name":"Go"}`, []string{"key"}},
+		{http.MethodPost, "/api/v1/skills", `{"key":"Go Lang","name":"Go","logo":"go.png","tags":["go","go"]}`, []string{"key", "logo", "tags[1]"}},
+		{http.MethodPatch, "/api/v1/skills/go/actions/name", `{}`, []string{"name"}},
+		{http.MethodPatch, "/api/v1/skills/go/actions/tags", `{}`, []string{"tags"}},
+	} {
+		ht := setupHandlerTest(t)
+
+		rec := ht.do(tc.method, tc.path, tc.body)
+		var body ValidationError
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s %s: expected 422, got %d %s", tc.method, tc.body, rec.Code, rec.Body)
+			continue
+		}
+		var fields []string
+		for _, fe := range body.Errors {
+			fields = append(fields, fe.Field)
+		}
+		if !reflect.DeepEqual(fields, tc.fields) {
+			t.Errorf("%s %s: expected errors on %v, got %v", tc.method, tc.body, tc.fields, fields)
+		}
+		if len(ht.publisher.Messages()) != 0 {
+			t.Errorf("%s %s: expected nothing published, got %v", tc.method, tc.body, ht.publisher.Messages())
+		}
+	}
+}
+
+func TestCommandHandlersCheckExistence(t *testing.T) {
+	ht := setupHandlerTest(t)
+	ht.router = gin.New()
+	admin := auth.Anonymous
+	admin.Roles = []string{"admin"}
+	ht.router.Use(as(admin))
+	NewHandler(ht.storage, ht.publisher, true).Routes(ht.router)
+
+	for _, key := range []string{"go", "cobol"} {
+		if _, err := ht.storage.PostSkill(context.Background(), Skill{Key: key, Name: key}, "tester"); err != nil {
+			t.Fatalf("PostSkill error: %v", err)
+		}
+	}
+	ht.storage.DeleteSkill(context.Background(), "cobol", "tester")
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodPost, "/api/v1/skills", `{"key":"go","name":"Go"}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/skills", `{"key":"cobol","name":"COBOL"}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/skills", `{"key":"rust","name":"Rust"}`, http.StatusOK},
+		{http.MethodPut, "/api/v1/skills/rust", `{"name":"Rust"}`, http.StatusNotFound},
+		{http.MethodPatch, "/api/v1/skills/cobol/actions/logo", `{"logo":"https://example.com/cobol.png"}`, http.StatusNotFound},
+		{http.MethodPatch, "/api/v1/skills/go/actions/logo", `{"logo":"https://example.com/go.png"}`, http.StatusOK},
+		{http.MethodPatch, "/api/v1/skills/go/actions/name", `{}`, http.StatusUnprocessableEntity},
+		{http.MethodDelete, "/api/v1/skills/rust", "", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/skills/go/restore", "", http.StatusConflict},
+		{http.MethodPost, "/api/v1/skills/rust/restore", "", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/skills/cobol/restore", "", http.StatusOK},
+	} {
+		ht.publisher.Reset()
+
+		rec := ht.do(tc.method, tc.path, tc.body)
+		if rec.Code != tc.status {
+			t.Errorf("%s %s: expected %d, got %d %s", tc.method, tc.path, tc.status, rec.Code, rec.Body)
+		}
+		if published := len(ht.publisher.Messages()) > 0; published != (tc.status == http.StatusOK) {
+			t.Errorf("%s %s: expected published to be %v, got %v", tc.method, tc.path, tc.status == http.StatusOK, ht.publisher.Messages())
+		}
+	}
+}
+
+func TestCommandHandlersStampActor(t *testing.T) {
+	ht := setupHandlerTest(t, as(auth.Principal{Subject: "alice", Method: "jwt", Roles: []string{"admin"}}))
+
+	ht.do(http.MethodDelete, "/api/v1/skills/go", "")
+
+	got := ht.published(t)
+	if len(got) != 1 || got[0].Actor != "alice" {
+		t.Errorf("Expected a message from alice, got %+v", got)
+	}
+}
+
+func TestRoutesRequirePermissions(t *testing.T) {
+	for _, tc := range []struct {
+		role         string
+		method, path string
+		status       int
+	}{
+		{"viewer", http.MethodGet, "/api/v1/skills", http.StatusOK},
+		{"viewer", http.MethodPost, "/api/v1/skills", http.StatusForbidden},
+		{"editor", http.MethodPost, "/api/v1/skills", http.StatusOK},
+		{"editor", http.MethodDelete, "/api/v1/skills/go", http.StatusForbidden},
+		{"editor", http.MethodPost, "/api/v1/events/event-1/revert", http.StatusForbidden},
+		{"admin", http.MethodDelete, "/api/v1/skills/go", http.StatusOK},
+	} {
+		ht := setupHandlerTest(t, as(auth.Principal{Subject: "alice", Method: "jwt", Roles: []string{tc.role}}))
+
+		rec := ht.do(tc.method, tc.path, `{"key":"go","name":"Go"}`)
+		if rec.Code != tc.status {
+			t.Errorf("%s %s %s: expected %d, got %d %s", tc.role, tc.method, tc.path, tc.status, rec.Code, rec.Body)
+		}
+		if rec.Code == http.StatusForbidden && len(ht.publisher.Messages()) != 0 {
+			t.Errorf("%s %s %s: expected nothing published, got %v", tc.role, tc.method, tc.path, ht.publisher.Messages())
+		}
+	}
+}
+
+// TestE2EKeyIsAdmin checks that the API key the e2e suite sends by default is
+// accepted as an admin's under e2e/api.config.yaml, as the suite deletes
+// skills.
+func TestE2EKeyIsAdmin(t *testing.T) {
+	cfg, _, err := config.LoadAPI([]string{"-config", "../../e2e/api.config.yaml"})
+	if err != nil {
+		t.Fatalf("can't load the e2e config: %v", err)
+	}
+	authenticator, err := auth.New(context.Background(), cfg.Auth)
+	if err != nil {
+		t.Fatalf("can't set up authentication: %v", err)
+	}
+	ht := setupHandlerTest(t, auth.Gin(authenticator))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/skills/go", nil)
+	req.Header.Set(auth.APIKeyHeader, "e2e-admin-key")
+	rec := httptest.NewRecorder()
+	ht.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the e2e key to delete skills, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRevisionHandlers(t *testing.T) {
+	ht := setupHandlerTest(t)
+	if _, err := ht.storage.PostSkill(context.Background(), Skill{Key: "go", Name: "Go 2", Tags: []string{}}, "tester"); err != nil {
+		t.Fatalf("PostSkill error: %v", err)
+	}
+	insert := `INSERT INTO skill_revision (key, rev, event_id, action, name, tags) VALUES
+	('go', 1, 'event-1', 'Insert', 'Go', '{}'),
+	('go', 2, 'event-2', 'UpdateName', 'Go 2', '{}'),
+	('rust', 1, 'event-2', 'Insert', 'Rust', '{}')`
+	if _, err := ht.storage.db.Exec(insert); err != nil {
+		t.Fatalf("can't insert revisions: %v", err)
+	}
+
+	t.Run("GetSkillRevisions", func(t *testing.T) {
+		rec := ht.do(http.MethodGet, "/api/v1/skills/go/revisions", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		var revisions []Revision
+		decodeData(t, rec, &revisions)
+		if len(revisions) != 2 || revisions[1].Skill.Name != "Go 2" {
+			t.Errorf("Unexpected revisions %v", revisions)
+		}
+	})
+
+	t.Run("RevertSkill", func(t *testing.T) {
+		ht.publisher.Reset()
+		rec := ht.do(http.MethodPost, "/api/v1/skills/go/revisions/1/revert", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+
+		got := ht.published(t)
+		if len(got) != 1 || got[0].Action != "Update" || got[0].Data.Name != "Go" {
+			t.Errorf("Expected an update back to Go, got %+v", got)
+		}
+	})
+
+	t.Run("RevertSkillMissingRevision", func(t *testing.T) {
+		rec := ht.do(http.MethodPost, "/api/v1/skills/go/revisions/9/revert", "")
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", rec.Code)
+		}
+	})
+
+	t.Run("RevertEvent", func(t *testing.T) {
+		ht.publisher.Reset()
+		rec := ht.do(http.MethodPost, "/api/v1/events/event-2/revert", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+
+		got := ht.published(t)
+		if len(got) != 2 {
+			t.Fatalf("Expected two messages, got %+v", got)
+		}
+		if got[0].Key != "go" || got[0].Action != "Update" || got[0].Data.Name != "Go" {
+			t.Errorf("Expected go to be reverted to rev 1, got %+v", got[0])
+		}
+		if got[1].Key != "rust" || got[1].Action != "DeleteSkill" {
+			t.Errorf("Expected rust to be deleted, got %+v", got[1])
+		}
+	})
+
+	t.Run("RevertEventBeforeRevisions", func(t *testing.T) {
+		// cobol was created before revisions were recorded, so its first
+		// revision is an update and its state before it is unknown.
+		if _, err := ht.storage.PostSkill(context.Background(), Skill{Key: "cobol", Name: "COBOL 2", Tags: []string{}}, "tester"); err != nil {
+			t.Fatalf("PostSkill error: %v", err)
+		}
+		if _, err := ht.storage.db.Exec(`INSERT INTO skill_revision (key, rev, event_id, action, name, tags) VALUES ('cobol', 1, 'event-3', 'UpdateName', 'COBOL 2', '{}')`); err != nil {
+			t.Fatalf("can't insert revisions: %v", err)
+		}
+
+		ht.publisher.Reset()
+		rec := ht.do(http.MethodPost, "/api/v1/events/event-3/revert", "")
+		if rec.Code != http.StatusConflict {
+			t.Errorf("Expected 409, got %d %s", rec.Code, rec.Body)
+		}
+		if got := ht.publisher.Messages(); len(got) != 0 {
+			t.Errorf("Expected nothing published, got %+v", got)
+		}
+	})
+
+	t.Run("RevertEventMissing", func(t *testing.T) {
+		rec := ht.do(http.MethodPost, "/api/v1/events/nope/revert", "")
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", rec.Code)
+		}
+	})
+}
+
+func TestRevertSkillDeletingNeedsAdmin(t *testing.T) {
+	for _, tc := range []struct {
+		role   string
+		rev    string
+		status int
+	}{
+		{"editor", "1", http.StatusOK},
+		{"editor", "2", http.StatusForbidden},
+		{"admin", "2", http.StatusOK},
+	} {
+		ht := setupHandlerTest(t, as(auth.Principal{Subject: "alice", Method: "jwt", Roles: []string{tc.role}}))
+		if _, err := ht.storage.PostSkill(context.Background(), Skill{Key: "go", Name: "Go", Tags: []string{}}, "tester"); err != nil {
+			t.Fatalf("PostSkill error: %v", err)
+		}
+		insert := `INSERT INTO skill_revision (key, rev, event_id, action, name, tags) VALUES
+		('go', 1, 'event-1', 'Insert', 'Go', '{}'),
+		('go', 2, 'event-2', 'DeleteSkill', 'Go', '{}'),
+		('go', 3, 'event-3', 'RestoreSkill', 'Go', '{}')`
+		if _, err := ht.storage.db.Exec(insert); err != nil {
+			t.Fatalf("can't insert revisions: %v", err)
+		}
+
+		rec := ht.do(http.MethodPost, "/api/v1/skills/go/revisions/"+tc.rev+"/revert", "")
+		if rec.Code != tc.status {
+			t.Errorf("%s reverting to rev %s: expected %d, got %d %s", tc.role, tc.rev, tc.status, rec.Code, rec.Body)
+		}
+		if published := len(ht.publisher.Messages()) > 0; published != (tc.status == http.StatusOK) {
+			t.Errorf("%s reverting to rev %s: expected published to be %v, got %v", tc.role, tc.rev, tc.status == http.StatusOK, ht.publisher.Messages())
+		}
+	}
+}
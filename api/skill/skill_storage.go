@@ -1,135 +1,305 @@
 package skill
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"fmt"
+	"strings"
+	"time"
 
-	"github.com/lib/pq"
+	"github.com/narunart-atise/skill-api-kafka/sqltags"
 )
 
 type storage struct {
+	db    *sql.DB
+	reads Reads
+}
+
+// Reads chooses the database each read goes to, so reads can be served by
+// replicas. Writes, and the reads returning what was just written, always go
+// to the primary.
+type Reads interface {
+	Reader() *sql.DB
+	// ListReader is used for listing skills.
+	ListReader() *sql.DB
+}
+
+// dbReads sends every read to db.
+type dbReads struct {
 	db *sql.DB
 }
 
-type storager interface {
-	FindAllSkill() ([]Skill, error)
-	FindSkillByKey(key string) (Skill, error)
-	PostSkill(skill Skill) (Skill, error)
-	EditSkill(skill Skill) (Skill, error)
-	EditSkillName(key string, name string) (Skill, error)
-	EditSkillDescription(key, description string) (Skill, error)
-	EditSkillLogo(key, logo string) (Skill, error)
-	EditSkillTags(key string, Tags []string) (Skill, error)
-	DeleteSkill(rowKey string) string
+func (p dbReads) Reader() *sql.DB     { return p.db }
+func (p dbReads) ListReader() *sql.DB { return p.db }
+
+// Storager reads and writes skills and reads their revisions. NewStorage
+// implements it on a Postgres or SQLite database and NewMemoryStorage on an
+// in-memory one.
+type Storager interface {
+	FindAllSkill(ctx context.Context, filter Filter) ([]Skill, error)
+	FindSkillByKey(ctx context.Context, key string) (Skill, error)
+	FindDeletedSkillByKey(ctx context.Context, key string) (Skill, error)
+	PostSkill(ctx context.Context, skill Skill, actor string) (Skill, error)
+	EditSkill(ctx context.Context, skill Skill, actor string) (Skill, error)
+	EditSkillName(ctx context.Context, key string, name string, actor string) (Skill, error)
+	EditSkillDescription(ctx context.Context, key, description string, actor string) (Skill, error)
+	EditSkillLogo(ctx context.Context, key, logo string, actor string) (Skill, error)
+	EditSkillTags(ctx context.Context, key string, Tags []string, actor string) (Skill, error)
+	DeleteSkill(ctx context.Context, rowKey, actor string) string
+	UpsertSkill(ctx context.Context, skill Skill, actor string) (Skill, error)
+	FindRevisionsByKey(ctx context.Context, key string) ([]Revision, error)
+	FindRevision(ctx context.Context, key string, rev int) (Revision, error)
+	FindRevisionsByEvent(ctx context.Context, eventID string) ([]Revision, error)
 }
 
 func NewStorage(db *sql.DB) *storage {
-	return &storage{db}
+	return &storage{db, dbReads{db}}
+}
+
+// NewReplicatedStorage writes to primary and reads through reads.
+func NewReplicatedStorage(primary *sql.DB, reads Reads) *storage {
+	return &storage{primary, reads}
+}
+
+// Filter narrows and orders the skills returned by FindAllSkill.
+type Filter struct {
+	// Deleted lists skills in the trash instead of live ones.
+	Deleted bool
+
+	CreatedBy     string
+	UpdatedBy     string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	// Sort is one of SortColumns; skills are ordered by key when empty.
+	Sort string
+	Desc bool
+}
+
+// SortColumns are the columns FindAllSkill can order by.
+var SortColumns = map[string]bool{
+	"key":        true,
+	"name":       true,
+	"created_at": true,
+	"updated_at": true,
 }
 
-func (s storage) FindAllSkill() ([]Skill, error) {
-	rows, err := s.db.Query("SELECT key, name, description,logo,tags FROM skill")
+const skillColumns = "key, name, description,logo,tags,deleted_at,created_at,updated_at,created_by,updated_by"
+
+func (f Filter) where() (string, []any) {
+	conds := []string{"deleted_at IS NULL"}
+	if f.Deleted {
+		conds = []string{"deleted_at IS NOT NULL"}
+	}
+
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.CreatedBy != "" {
+		add("created_by=$%d", f.CreatedBy)
+	}
+	if f.UpdatedBy != "" {
+		add("updated_by=$%d", f.UpdatedBy)
+	}
+	if !f.CreatedAfter.IsZero() {
+		add("created_at>=$%d", f.CreatedAfter.UTC())
+	}
+	if !f.CreatedBefore.IsZero() {
+		add("created_at<$%d", f.CreatedBefore.UTC())
+	}
+	if !f.UpdatedAfter.IsZero() {
+		add("updated_at>=$%d", f.UpdatedAfter.UTC())
+	}
+	if !f.UpdatedBefore.IsZero() {
+		add("updated_at<$%d", f.UpdatedBefore.UTC())
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+func (f Filter) orderBy() string {
+	column := "key"
+	if SortColumns[f.Sort] {
+		column = f.Sort
+	}
+	if f.Desc {
+		return column + " DESC, key"
+	}
+	return column + ", key"
+}
+
+func (s storage) FindAllSkill(ctx context.Context, filter Filter) ([]Skill, error) {
+	where, args := filter.where()
+	q := "SELECT " + skillColumns + " FROM skill WHERE " + where + " ORDER BY " + filter.orderBy()
+
+	rows, err := s.reads.ListReader().QueryContext(ctx, q, args...)
 	if err != nil {
-		return []Skill{}, nil
+		return nil, err
 	}
+	defer rows.Close()
 
 	var Skills []Skill
 	for rows.Next() {
-		var skill Skill
-		err := rows.Scan(&skill.Key, &skill.Name, &skill.Description, &skill.Logo, pq.Array(&skill.Tags))
+		skill, err := scanSkill(rows)
 		if err != nil {
-			log.Fatal("can't Scan row into variable", err)
+			return nil, err
 		}
 
-		Skills = append(Skills, Skill{
-			Key:         skill.Key,
-			Name:        skill.Name,
-			Description: skill.Description,
-			Logo:        skill.Logo,
-			Tags:        skill.Tags,
-		})
+		Skills = append(Skills, skill)
 	}
 
-	return Skills, nil
+	return Skills, rows.Err()
 }
 
-func (s storage) FindSkillByKey(key string) (Skill, error) {
-	q := "SELECT key, name, description,logo,tags FROM skill WHERE key=$1"
-	row := s.db.QueryRow(q, key)
+func (s storage) FindSkillByKey(ctx context.Context, key string) (Skill, error) {
+	return findSkillByKey(ctx, s.reads.Reader(), key)
+}
+
+func findSkillByKey(ctx context.Context, db *sql.DB, key string) (Skill, error) {
+	q := "SELECT " + skillColumns + " FROM skill WHERE key=$1 AND deleted_at IS NULL"
+	return scanSkill(db.QueryRowContext(ctx, q, key))
+}
+
+func (s storage) FindDeletedSkillByKey(ctx context.Context, key string) (Skill, error) {
+	q := "SELECT " + skillColumns + " FROM skill WHERE key=$1 AND deleted_at IS NOT NULL"
+	return scanSkill(s.reads.Reader().QueryRowContext(ctx, q, key))
+}
 
+func scanSkill(row interface{ Scan(...any) error }) (Skill, error) {
 	var skill Skill
-	err := row.Scan(&skill.Key, &skill.Name, &skill.Description, &skill.Logo, pq.Array(&skill.Tags))
+	var deletedAt sql.NullTime
+	err := row.Scan(&skill.Key, &skill.Name, &skill.Description, &skill.Logo, (*sqltags.Array)(&skill.Tags), &deletedAt,
+		&skill.CreatedAt, &skill.UpdatedAt, &skill.CreatedBy, &skill.UpdatedBy)
 	if err != nil {
 		return Skill{}, err
 	}
 
-	return Skill{
-		Key:         skill.Key,
-		Name:        skill.Name,
-		Description: skill.Description,
-		Logo:        skill.Logo,
-		Tags:        skill.Tags,
-	}, nil
+	if deletedAt.Valid {
+		skill.DeletedAt = &deletedAt.Time
+	}
+	return skill, nil
 }
 
-func (s storage) PostSkill(skill Skill) (Skill, error) {
-	q := "INSERT INTO skill (key,name, description,logo,tags) values ($1, $2,$3,$4,$5) RETURNING key"
-	row := s.db.QueryRow(q, skill.Key, skill.Name, skill.Description, skill.Logo, pq.Array(skill.Tags))
+func (s storage) PostSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	q := "INSERT INTO skill (key,name, description,logo,tags,created_at,updated_at,created_by,updated_by) values ($1, $2,$3,$4,$5,$6,$6,$7,$7) RETURNING key"
+	row := s.db.QueryRowContext(ctx, q, skill.Key, skill.Name, skill.Description, skill.Logo, sqltags.Array(skill.Tags), time.Now().UTC(), actor)
 
 	var keyid string
 	err := row.Scan(&keyid)
 	if err != nil {
 		return Skill{}, err
 	}
-	return s.FindSkillByKey(keyid)
+	return findSkillByKey(ctx, s.db, keyid)
 }
 
-func (s storage) EditSkill(skill Skill) (Skill, error) {
-	q := "UPDATE skill SET name=$2, description=$3, logo=$4, tags=$5 WHERE key=$1;"
-	if _, err := s.db.Exec(q, skill.Key, skill.Name, skill.Description, skill.Logo, pq.Array(skill.Tags)); err != nil {
+func (s storage) EditSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	q := "UPDATE skill SET name=$2, description=$3, logo=$4, tags=$5, updated_at=$6, updated_by=$7 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, skill.Key, skill.Name, skill.Description, skill.Logo, sqltags.Array(skill.Tags), time.Now().UTC(), actor); err != nil {
 		return Skill{}, err
 	}
 
-	return s.FindSkillByKey(skill.Key)
+	return findSkillByKey(ctx, s.db, skill.Key)
 }
 
-func (s storage) EditSkillName(key string, name string) (Skill, error) {
-	q := "UPDATE skill SET name=$2 WHERE key=$1;"
-	if _, err := s.db.Exec(q, key, name); err != nil {
+func (s storage) EditSkillName(ctx context.Context, key string, name string, actor string) (Skill, error) {
+	q := "UPDATE skill SET name=$2, updated_at=$3, updated_by=$4 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, key, name, time.Now().UTC(), actor); err != nil {
 		return Skill{}, err
 	}
-	return s.FindSkillByKey(key)
+	return findSkillByKey(ctx, s.db, key)
 }
 
-func (s storage) EditSkillDescription(key, description string) (Skill, error) {
-	q := "UPDATE skill SET description=$2 WHERE key=$1;"
-	if _, err := s.db.Exec(q, key, description); err != nil {
+func (s storage) EditSkillDescription(ctx context.Context, key, description string, actor string) (Skill, error) {
+	q := "UPDATE skill SET description=$2, updated_at=$3, updated_by=$4 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, key, description, time.Now().UTC(), actor); err != nil {
 		return Skill{}, err
 	}
-	return s.FindSkillByKey(key)
+	return findSkillByKey(ctx, s.db, key)
 }
 
-func (s storage) EditSkillLogo(key, logo string) (Skill, error) {
-	q := "UPDATE skill SET logo=$2 WHERE key=$1;"
-	if _, err := s.db.Exec(q, key, logo); err != nil {
+func (s storage) EditSkillLogo(ctx context.Context, key, logo string, actor string) (Skill, error) {
+	q := "UPDATE skill SET logo=$2, updated_at=$3, updated_by=$4 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, key, logo, time.Now().UTC(), actor); err != nil {
 		return Skill{}, err
 	}
-	return s.FindSkillByKey(key)
+	return findSkillByKey(ctx, s.db, key)
 }
 
-func (s storage) EditSkillTags(key string, Tags []string) (Skill, error) {
-	q := "UPDATE skill SET tags=$2 WHERE key=$1;"
-	if _, err := s.db.Exec(q, key, pq.Array(Tags)); err != nil {
+func (s storage) EditSkillTags(ctx context.Context, key string, Tags []string, actor string) (Skill, error) {
+	q := "UPDATE skill SET tags=$2, updated_at=$3, updated_by=$4 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, key, sqltags.Array(Tags), time.Now().UTC(), actor); err != nil {
 		return Skill{}, err
 	}
-	return s.FindSkillByKey(key)
+	return findSkillByKey(ctx, s.db, key)
 }
 
-func (s storage) DeleteSkill(rowKey string) string {
-	q := "DELETE FROM skill WHERE key=$1;"
-	if _, err := s.db.Exec(q, rowKey); err != nil {
+func (s storage) DeleteSkill(ctx context.Context, rowKey, actor string) string {
+	q := "UPDATE skill SET deleted_at=$2, updated_at=$2, updated_by=$3 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, rowKey, time.Now().UTC(), actor); err != nil {
 		return "fail"
 	}
 
 	return "success"
 }
+
+// UpsertSkill inserts skill or overwrites the existing one with the same key,
+// restoring it from the trash if needed.
+func (s storage) UpsertSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	q := `INSERT INTO skill (key,name, description,logo,tags,created_at,updated_at,created_by,updated_by) values ($1, $2,$3,$4,$5,$6,$6,$7,$7)
+	ON CONFLICT (key) DO UPDATE SET name=EXCLUDED.name, description=EXCLUDED.description, logo=EXCLUDED.logo, tags=EXCLUDED.tags,
+	updated_at=EXCLUDED.updated_at, updated_by=EXCLUDED.updated_by, deleted_at=NULL`
+	if _, err := s.db.ExecContext(ctx, q, skill.Key, skill.Name, skill.Description, skill.Logo, sqltags.Array(skill.Tags), time.Now().UTC(), actor); err != nil {
+		return Skill{}, err
+	}
+	return findSkillByKey(ctx, s.db, skill.Key)
+}
+
+const revisionColumns = "key, rev, event_id, action, actor, name, description, logo, tags, created_at"
+
+func scanRevision(row interface{ Scan(...any) error }) (Revision, error) {
+	var r Revision
+	err := row.Scan(&r.Key, &r.Rev, &r.EventID, &r.Action, &r.Actor, &r.Skill.Name, &r.Skill.Description, &r.Skill.Logo, (*sqltags.Array)(&r.Skill.Tags), &r.CreatedAt)
+	if err != nil {
+		return Revision{}, err
+	}
+	r.Skill.Key = r.Key
+	return r, nil
+}
+
+func (s storage) findRevisions(ctx context.Context, q string, args ...any) ([]Revision, error) {
+	rows, err := s.reads.Reader().QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (s storage) FindRevisionsByKey(ctx context.Context, key string) ([]Revision, error) {
+	q := "SELECT " + revisionColumns + " FROM skill_revision WHERE key=$1 ORDER BY rev"
+	return s.findRevisions(ctx, q, key)
+}
+
+func (s storage) FindRevision(ctx context.Context, key string, rev int) (Revision, error) {
+	q := "SELECT " + revisionColumns + " FROM skill_revision WHERE key=$1 AND rev=$2"
+	return scanRevision(s.reads.Reader().QueryRowContext(ctx, q, key, rev))
+}
+
+func (s storage) FindRevisionsByEvent(ctx context.Context, eventID string) ([]Revision, error) {
+	q := "SELECT " + revisionColumns + " FROM skill_revision WHERE event_id=$1 ORDER BY key, rev"
+	return s.findRevisions(ctx, q, eventID)
+}
@@ -1,11 +1,19 @@
 package skill
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
+	"os"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/narunart-atise/skill-api-kafka/api/database"
+	"github.com/narunart-atise/skill-api-kafka/memdb"
+	"github.com/narunart-atise/skill-api-kafka/migrations"
+	"github.com/narunart-atise/skill-api-kafka/sqltags"
 	_ "modernc.org/sqlite"
 )
 
@@ -15,17 +23,7 @@ func setupTestDB() *sql.DB {
 		log.Fatal(err)
 	}
 
-	createTb := `
-	CREATE TABLE IF NOT EXISTS skill (
-	key TEXT PRIMARY KEY,
-	name TEXT NOT NULL DEFAULT '',
-	description TEXT NOT NULL DEFAULT '',
-	logo TEXT NOT NULL DEFAULT '',
-	tags TEXT [] NOT NULL DEFAULT '{}'
-);
-	`
-
-	_, err = db.Exec(createTb)
+	_, err = db.Exec(migrations.SQLiteSchema)
 	if err != nil {
 		log.Fatal("can't create table", err)
 	}
@@ -33,11 +31,87 @@ func setupTestDB() *sql.DB {
 	return db
 }
 
+// storageBackend is a Storager under test with empty tables. addRevision
+// records a revision the way the consumer does, as the API only reads them.
+type storageBackend struct {
+	storage     Storager
+	addRevision func(r Revision) error
+}
+
+// forEachBackend runs test against every Storager implementation. Postgres
+// is only tested when TEST_DATABASE_URL points at a database it may wipe.
+func forEachBackend(t *testing.T, test func(t *testing.T, b storageBackend)) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) storageBackend
+	}{
+		{"sqlite", func(t *testing.T) storageBackend {
+			db := setupTestDB()
+			t.Cleanup(func() { db.Close() })
+			return sqlBackend(t, db)
+		}},
+		{"memory", func(t *testing.T) storageBackend {
+			mem := memdb.New()
+			return storageBackend{
+				storage: NewMemoryStorage(mem),
+				addRevision: func(r Revision) error {
+					return mem.Update(func(tx memdb.Tx) error {
+						tx.AddRevision(memdb.Revision{Key: r.Key, EventID: r.EventID, Action: r.Action, Name: r.Skill.Name, Tags: r.Skill.Tags, CreatedAt: time.Now().UTC()})
+						return nil
+					})
+				},
+			}
+		}},
+		{"postgres", func(t *testing.T) storageBackend {
+			url := os.Getenv("TEST_DATABASE_URL")
+			if url == "" {
+				t.Skip("TEST_DATABASE_URL is not set")
+			}
+			db, closeDB := database.NewPostgres(url)
+			t.Cleanup(closeDB)
+
+			m, err := migrations.New(db)
+			if err != nil {
+				t.Fatalf("can't load migrations: %v", err)
+			}
+			if err := m.Up(context.Background()); err != nil {
+				t.Fatalf("can't migrate: %v", err)
+			}
+			return sqlBackend(t, db)
+		}},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.open(t))
+		})
+	}
+}
+
+func sqlBackend(t *testing.T, db *sql.DB) storageBackend {
+	if _, err := db.Exec("DELETE FROM skill"); err != nil {
+		t.Fatalf("can't clear skills: %v", err)
+	}
+	if _, err := db.Exec("DELETE FROM skill_revision"); err != nil {
+		t.Fatalf("can't clear revisions: %v", err)
+	}
+
+	return storageBackend{
+		storage: NewStorage(db),
+		addRevision: func(r Revision) error {
+			q := "INSERT INTO skill_revision (key, rev, event_id, action, name, tags, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+			_, err := db.Exec(q, r.Key, r.Rev, r.EventID, r.Action, r.Skill.Name, sqltags.Array(r.Skill.Tags), time.Now().UTC())
+			return err
+		},
+	}
+}
+
 func TestSkillStorage(t *testing.T) {
-	db := setupTestDB()
-	defer db.Close()
+	forEachBackend(t, testSkillStorage)
+}
 
-	storage := NewStorage(db)
+func testSkillStorage(t *testing.T, b storageBackend) {
+	storage := b.storage
 
 	testSkill := Skill{
 		Key:         "test-skill",
@@ -48,7 +122,7 @@ func TestSkillStorage(t *testing.T) {
 	}
 
 	t.Run("PostSkill", func(t *testing.T) {
-		createdSkill, err := storage.PostSkill(testSkill)
+		createdSkill, err := storage.PostSkill(context.Background(), testSkill, "tester")
 		if err != nil {
 			t.Fatalf("PostSkill error: %v", err)
 		}
@@ -57,8 +131,14 @@ func TestSkillStorage(t *testing.T) {
 		}
 	})
 
+	t.Run("PostSkillDuplicate", func(t *testing.T) {
+		if _, err := storage.PostSkill(context.Background(), testSkill, "tester"); err == nil {
+			t.Error("Expected an error posting an existing key")
+		}
+	})
+
 	t.Run("FindAllSkill", func(t *testing.T) {
-		skills, err := storage.FindAllSkill()
+		skills, err := storage.FindAllSkill(context.Background(), Filter{})
 		if err != nil {
 			t.Fatalf("FindAllSkill error: %v", err)
 		}
@@ -67,8 +147,26 @@ func TestSkillStorage(t *testing.T) {
 		}
 	})
 
+	t.Run("FindAllSkillFilter", func(t *testing.T) {
+		skills, err := storage.FindAllSkill(context.Background(), Filter{CreatedBy: "tester", UpdatedAfter: time.Now().Add(-time.Hour), Sort: "updated_at", Desc: true})
+		if err != nil {
+			t.Fatalf("FindAllSkill error: %v", err)
+		}
+		if len(skills) != 1 || skills[0].Key != testSkill.Key {
+			t.Errorf("Expected only %s, got %v", testSkill.Key, skills)
+		}
+
+		skills, err = storage.FindAllSkill(context.Background(), Filter{CreatedBy: "someone-else"})
+		if err != nil {
+			t.Fatalf("FindAllSkill error: %v", err)
+		}
+		if len(skills) != 0 {
+			t.Errorf("Expected no skills, got %v", skills)
+		}
+	})
+
 	t.Run("FindSkillByKey", func(t *testing.T) {
-		foundSkill, err := storage.FindSkillByKey(testSkill.Key)
+		foundSkill, err := storage.FindSkillByKey(context.Background(), testSkill.Key)
 		if err != nil {
 			t.Fatalf("FindSkillByKey error: %v", err)
 		}
@@ -77,6 +175,15 @@ func TestSkillStorage(t *testing.T) {
 		}
 	})
 
+	t.Run("FindSkillByKeyMissing", func(t *testing.T) {
+		if _, err := storage.FindSkillByKey(context.Background(), "no-such-skill"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+		if _, err := storage.EditSkillName(context.Background(), "no-such-skill", "Name", "editor"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows editing a missing skill, got %v", err)
+		}
+	})
+
 	t.Run("EditSkill", func(t *testing.T) {
 		testEditSkill := Skill{
 			Key:         "test-skill",
@@ -85,10 +192,19 @@ func TestSkillStorage(t *testing.T) {
 			Logo:        "Edit-logo-url",
 			Tags:        []string{"Edittag1", "Edittag2"},
 		}
-		updatedSkill, err := storage.EditSkill(testEditSkill)
+		updatedSkill, err := storage.EditSkill(context.Background(), testEditSkill, "editor")
 		if err != nil {
 			t.Fatalf("EditSkill error: %v", err)
 		}
+		if updatedSkill.CreatedBy != "tester" || updatedSkill.UpdatedBy != "editor" {
+			t.Errorf("Expected created_by tester and updated_by editor, got %s and %s", updatedSkill.CreatedBy, updatedSkill.UpdatedBy)
+		}
+		if updatedSkill.UpdatedAt.Before(updatedSkill.CreatedAt) {
+			t.Errorf("Expected updated_at %v not before created_at %v", updatedSkill.UpdatedAt, updatedSkill.CreatedAt)
+		}
+
+		updatedSkill.CreatedAt, updatedSkill.UpdatedAt = time.Time{}, time.Time{}
+		updatedSkill.CreatedBy, updatedSkill.UpdatedBy = "", ""
 		if !reflect.DeepEqual(updatedSkill, testEditSkill) {
 			t.Errorf("Expected skill %v, got %v", testEditSkill, updatedSkill)
 		}
@@ -96,7 +212,7 @@ func TestSkillStorage(t *testing.T) {
 
 	t.Run("EditSkillName", func(t *testing.T) {
 		newName := "Updated Test Skill"
-		updatedSkill, err := storage.EditSkillName(testSkill.Key, newName)
+		updatedSkill, err := storage.EditSkillName(context.Background(), testSkill.Key, newName, "editor")
 		if err != nil {
 			t.Fatalf("EditSkill error: %v", err)
 		}
@@ -107,7 +223,7 @@ func TestSkillStorage(t *testing.T) {
 
 	t.Run("EditSkillDescription", func(t *testing.T) {
 		newDescription := "Updated Description"
-		updatedSkill, err := storage.EditSkillDescription(testSkill.Key, newDescription)
+		updatedSkill, err := storage.EditSkillDescription(context.Background(), testSkill.Key, newDescription, "editor")
 		if err != nil {
 			t.Fatalf("EditSkill error: %v", err)
 		}
@@ -118,7 +234,7 @@ func TestSkillStorage(t *testing.T) {
 
 	t.Run("EditSkillLogo", func(t *testing.T) {
 		newLogo := "Updated Logo"
-		updatedSkill, err := storage.EditSkillLogo(testSkill.Key, newLogo)
+		updatedSkill, err := storage.EditSkillLogo(context.Background(), testSkill.Key, newLogo, "editor")
 		if err != nil {
 			t.Fatalf("EditSkill error: %v", err)
 		}
@@ -129,7 +245,7 @@ func TestSkillStorage(t *testing.T) {
 
 	t.Run("EditSkillTags", func(t *testing.T) {
 		newTags := []string{"Updatedtag1", "Updatedtag2"}
-		updatedSkill, err := storage.EditSkillTags(testSkill.Key, newTags)
+		updatedSkill, err := storage.EditSkillTags(context.Background(), testSkill.Key, newTags, "editor")
 		if err != nil {
 			t.Fatalf("EditSkill error: %v", err)
 		}
@@ -137,10 +253,165 @@ func TestSkillStorage(t *testing.T) {
 			t.Errorf("Expected tags %v, got %v", newTags, updatedSkill.Tags)
 		}
 	})
+
+	t.Run("EditSkillTagsEscaping", func(t *testing.T) {
+		tags := []string{"with,comma", `with "quote"`, `back\slash`, "with space", "{braces}", "NULL", ""}
+		updatedSkill, err := storage.EditSkillTags(context.Background(), testSkill.Key, tags, "editor")
+		if err != nil {
+			t.Fatalf("EditSkillTags error: %v", err)
+		}
+		if !reflect.DeepEqual(updatedSkill.Tags, tags) {
+			t.Errorf("Expected tags %q, got %q", tags, updatedSkill.Tags)
+		}
+	})
+
+	t.Run("UpsertSkill", func(t *testing.T) {
+		upserted, err := storage.UpsertSkill(context.Background(), Skill{Key: testSkill.Key, Name: "Upserted", Tags: []string{}}, "seed")
+		if err != nil {
+			t.Fatalf("UpsertSkill error: %v", err)
+		}
+		if upserted.Name != "Upserted" || upserted.CreatedBy != "tester" || upserted.UpdatedBy != "seed" {
+			t.Errorf("Expected existing skill to be overwritten, got %v", upserted)
+		}
+	})
+
 	t.Run("DeleteSkill", func(t *testing.T) {
-		result := storage.DeleteSkill(testSkill.Key)
+		result := storage.DeleteSkill(context.Background(), testSkill.Key, "editor")
 		if result != "success" {
 			t.Errorf("DeleteSkill failed, expected 'success', got '%s'", result)
 		}
+		if _, err := storage.FindSkillByKey(context.Background(), testSkill.Key); err == nil {
+			t.Error("Expected deleted skill to be hidden from FindSkillByKey")
+		}
+	})
+
+	t.Run("FindDeletedSkill", func(t *testing.T) {
+		deletedSkill, err := storage.FindDeletedSkillByKey(context.Background(), testSkill.Key)
+		if err != nil {
+			t.Fatalf("FindDeletedSkillByKey error: %v", err)
+		}
+		if deletedSkill.DeletedAt == nil {
+			t.Error("Expected deleted_at to be set")
+		}
+
+		skills, err := storage.FindAllSkill(context.Background(), Filter{Deleted: true})
+		if err != nil {
+			t.Fatalf("FindAllSkill error: %v", err)
+		}
+		if len(skills) != 1 || skills[0].Key != testSkill.Key {
+			t.Errorf("Expected only %s in the trash, got %v", testSkill.Key, skills)
+		}
+	})
+
+	t.Run("UpsertSkillRestores", func(t *testing.T) {
+		restored, err := storage.UpsertSkill(context.Background(), testSkill, "seed")
+		if err != nil {
+			t.Fatalf("UpsertSkill error: %v", err)
+		}
+		if restored.DeletedAt != nil || restored.Name != testSkill.Name {
+			t.Errorf("Expected the skill to be restored, got %v", restored)
+		}
+	})
+}
+
+func TestRevisionStorage(t *testing.T) {
+	forEachBackend(t, testRevisionStorage)
+}
+
+func testRevisionStorage(t *testing.T, b storageBackend) {
+	storage := b.storage
+
+	for _, r := range []Revision{
+		{Key: "rev-skill", Rev: 1, EventID: "event-1", Action: "Insert", Skill: Skill{Name: "First", Tags: []string{"a"}}},
+		{Key: "rev-skill", Rev: 2, EventID: "event-2", Action: "UpdateName", Skill: Skill{Name: "Second", Tags: []string{"a"}}},
+		{Key: "other-skill", Rev: 1, EventID: "event-2", Action: "Insert", Skill: Skill{Name: "Other", Tags: []string{}}},
+	} {
+		if err := b.addRevision(r); err != nil {
+			t.Fatalf("can't insert revisions: %v", err)
+		}
+	}
+
+	t.Run("FindRevisionsByKey", func(t *testing.T) {
+		revisions, err := storage.FindRevisionsByKey(context.Background(), "rev-skill")
+		if err != nil {
+			t.Fatalf("FindRevisionsByKey error: %v", err)
+		}
+		if len(revisions) != 2 || revisions[0].Rev != 1 || revisions[1].Skill.Name != "Second" {
+			t.Errorf("Unexpected revisions %v", revisions)
+		}
 	})
+
+	t.Run("FindRevision", func(t *testing.T) {
+		revision, err := storage.FindRevision(context.Background(), "rev-skill", 1)
+		if err != nil {
+			t.Fatalf("FindRevision error: %v", err)
+		}
+		if revision.Skill.Key != "rev-skill" || revision.Skill.Name != "First" || revision.CreatedAt.IsZero() {
+			t.Errorf("Unexpected revision %v", revision)
+		}
+	})
+
+	t.Run("FindRevisionMissing", func(t *testing.T) {
+		if _, err := storage.FindRevision(context.Background(), "rev-skill", 3); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+
+		revisions, err := storage.FindRevisionsByKey(context.Background(), "no-such-skill")
+		if err != nil {
+			t.Fatalf("FindRevisionsByKey error: %v", err)
+		}
+		if revisions == nil || len(revisions) != 0 {
+			t.Errorf("Expected an empty list, got %#v", revisions)
+		}
+	})
+
+	t.Run("FindRevisionsByEvent", func(t *testing.T) {
+		revisions, err := storage.FindRevisionsByEvent(context.Background(), "event-2")
+		if err != nil {
+			t.Fatalf("FindRevisionsByEvent error: %v", err)
+		}
+		if len(revisions) != 2 {
+			t.Errorf("Expected 2 revisions, got %d", len(revisions))
+		}
+	})
+}
+
+func TestReplicatedStorage(t *testing.T) {
+	primary := setupTestDB()
+	defer primary.Close()
+	sqlBackend(t, primary)
+
+	replica, err := sql.Open("sqlite", "file:TestReplicatedStorage?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replica.Close()
+	if _, err := replica.Exec(migrations.SQLiteSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	storage := NewReplicatedStorage(primary, dbReads{replica})
+
+	created, err := storage.PostSkill(context.Background(), Skill{Key: "go", Name: "Go"}, "tester")
+	if err != nil {
+		t.Fatalf("PostSkill error: %v", err)
+	}
+	if created.Key != "go" {
+		t.Errorf("Expected the write to be read back from the primary, got %v", created)
+	}
+
+	if _, err := storage.FindSkillByKey(context.Background(), "go"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected reads to go to the replica, which lacks the skill, got %v", err)
+	}
+	if skills, _ := storage.FindAllSkill(context.Background(), Filter{}); len(skills) != 0 {
+		t.Errorf("Expected lists to go to the replica, got %v", skills)
+	}
+
+	// A failing replica fails the list instead of making it look empty.
+	if _, err := replica.Exec("DROP TABLE skill"); err != nil {
+		t.Fatal(err)
+	}
+	if skills, err := storage.FindAllSkill(context.Background(), Filter{}); err == nil {
+		t.Errorf("Expected the replica's error, got %v", skills)
+	}
 }
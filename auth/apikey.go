@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/narunart-atise/skill-api-kafka/config"
+)
+
+// APIKeyHeader carries an API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates requests carrying one of a static set of API keys in
+// the X-API-Key header. Each key grants one role, and only its SHA-256 is
+// known.
+type APIKeys struct {
+	keys []apiKey
+}
+
+type apiKey struct {
+	name string
+	role string
+	hash []byte
+}
+
+// NewAPIKeys parses name:role:sha256-hex triples.
+func NewAPIKeys(entries []string) (*APIKeys, error) {
+	a := &APIKeys{}
+	for i, entry := range entries {
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" || !slices.Contains(config.Roles, parts[1]) {
+			return nil, fmt.Errorf("auth: API key %d is not name:role:sha256-hex", i)
+		}
+		hash, err := hex.DecodeString(parts[2])
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("auth: API key %d is not name:role:sha256-hex", i)
+		}
+		a.keys = append(a.keys, apiKey{name: parts[0], role: parts[1], hash: hash})
+	}
+	return a, nil
+}
+
+func (a *APIKeys) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	// Every hash is compared, in constant time, so the time taken tells
+	// nothing of which key came close.
+	sum := sha256.Sum256([]byte(key))
+	var found *apiKey
+	for i, k := range a.keys {
+		if subtle.ConstantTimeCompare(sum[:], k.hash) == 1 {
+			found = &a.keys[i]
+		}
+	}
+	if found == nil {
+		return Principal{}, errors.New("auth: unknown API key")
+	}
+	return Principal{Subject: found.name, Method: "api_key", Roles: []string{found.role}}, nil
+}
@@ -0,0 +1,140 @@
+// Package auth authenticates the callers of the skill API with JWT bearer
+// tokens or API keys. The resulting principal travels in the request context
+// and names the actor of every command the request publishes. Its roles decide
+// which routes the caller may use.
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/narunart-atise/skill-api-kafka/config"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries
+// no credentials of its kind, letting the next one try.
+var ErrNoCredentials = errors.New("auth: no credentials")
+
+// Principal is an authenticated caller.
+type Principal struct {
+	// Subject is the sub claim of a token or the name of an API key.
+	Subject string
+	// Method is how the caller authenticated: jwt, api_key or anonymous.
+	Method string
+	// Roles are the roles of config.Roles granted to the caller.
+	Roles []string
+}
+
+// Anonymous is the principal of requests let through without credentials,
+// before it is given the configured role.
+var Anonymous = Principal{Subject: "anonymous", Method: "anonymous"}
+
+// Actor names the principal in the records of the changes it makes. API
+// keys are told apart from token subjects by a prefix.
+func (p Principal) Actor() string {
+	if p.Method == "api_key" {
+		return "apikey:" + p.Subject
+	}
+	return p.Subject
+}
+
+// Client names the caller of c for limits and caches kept per caller: its
+// actor, or its address when it is anonymous. It runs after Gin.
+func Client(c *gin.Context) string {
+	if p, ok := FromContext(c.Request.Context()); ok && p.Method != Anonymous.Method {
+		return p.Actor()
+	}
+	return "ip:" + c.ClientIP()
+}
+
+type Authenticator interface {
+	// Authenticate returns the principal behind the credentials of r, or
+	// ErrNoCredentials when r has none it understands.
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal carried by ctx.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// New returns the authenticator configured by cfg, trying bearer tokens and
+// then API keys. A JWK Set URL is refreshed in the background until ctx is
+// cancelled.
+func New(ctx context.Context, cfg config.Auth) (Authenticator, error) {
+	var chain Chain
+	if cfg.JWT.Enabled() {
+		tokens, err := NewJWT(ctx, cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, tokens)
+	}
+	if len(cfg.APIKeys) > 0 {
+		keys, err := NewAPIKeys(cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, keys)
+	}
+	if cfg.Anonymous {
+		chain = append(chain, anonymous{role: cfg.AnonymousRole})
+	}
+	return chain, nil
+}
+
+// Chain asks each authenticator in turn and settles on the first that finds
+// credentials it understands, valid or not.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if !errors.Is(err, ErrNoCredentials) {
+			return p, err
+		}
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+type anonymous struct {
+	role string
+}
+
+func (a anonymous) Authenticate(*http.Request) (Principal, error) {
+	p := Anonymous
+	p.Roles = []string{a.role}
+	return p, nil
+}
+
+// Gin authenticates every request with a and rejects those it fails with 401.
+// The principal of the others is put in the request context.
+func Gin(a Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := a.Authenticate(c.Request)
+		if err != nil {
+			message := "invalid credentials"
+			if errors.Is(err, ErrNoCredentials) {
+				message = "credentials required"
+			}
+			audit(c, "Authentication failed", "authentication_failed", Principal{}, slog.String("error", err.Error()))
+			c.Header("WWW-Authenticate", `Bearer realm="skill-api"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "message": message})
+			return
+		}
+
+		c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), p))
+		c.Next()
+	}
+}
@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/narunart-atise/skill-api-kafka/config"
+)
+
+// issuer signs tokens with a fresh RSA key published as a JWK Set.
+type issuer struct {
+	key  *rsa.PrivateKey
+	jwks []byte
+}
+
+func newIssuer(t *testing.T) issuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, _ := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "test",
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	return issuer{key: key, jwks: jwks}
+}
+
+func (i issuer) token(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(i.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func claims(overrides jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"iss": "https://idp",
+		"aud": "skill-api",
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range overrides {
+		c[name] = value
+	}
+	return c
+}
+
+func request(header, value string) *http.Request {
+	r := httptest.NewRequest(http.MethodDelete, "/api/v1/skills/go", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return r
+}
+
+func TestJWT(t *testing.T) {
+	iss := newIssuer(t)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, iss.jwks, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewJWT(context.Background(), config.AuthJWT{JWKSFile: file, Issuer: "https://idp", Audience: "skill-api", RolesClaim: "roles"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := a.Authenticate(request("Authorization", "Bearer "+iss.token(t, claims(jwt.MapClaims{"roles": []string{"editor", "owner"}}))))
+	if err != nil || p.Subject != "alice" || p.Method != "jwt" || !slices.Equal(p.Roles, []string{"editor"}) {
+		t.Errorf("Expected alice as editor, got %+v, %v", p, err)
+	}
+	p, err = a.Authenticate(request("Authorization", "Bearer "+iss.token(t, claims(jwt.MapClaims{"roles": "viewer admin"}))))
+	if err != nil || !slices.Equal(p.Roles, []string{"viewer", "admin"}) {
+		t.Errorf("Expected the roles of a space separated claim, got %+v, %v", p, err)
+	}
+	p, err = a.Authenticate(request("Authorization", "Bearer "+iss.token(t, claims(nil))))
+	if err != nil || len(p.Roles) != 0 {
+		t.Errorf("Expected no roles without the claim, got %+v, %v", p, err)
+	}
+
+	for name, token := range map[string]string{
+		"audience": iss.token(t, claims(jwt.MapClaims{"aud": "other"})),
+		"issuer":   iss.token(t, claims(jwt.MapClaims{"iss": "https://other"})),
+		"expired":  iss.token(t, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"no exp":   iss.token(t, jwt.MapClaims{"iss": "https://idp", "aud": "skill-api", "sub": "alice"}),
+		"key":      newIssuer(t).token(t, claims(nil)),
+		"garbage":  "not.a.token",
+	} {
+		if _, err := a.Authenticate(request("Authorization", "Bearer "+token)); err == nil || errors.Is(err, ErrNoCredentials) {
+			t.Errorf("%s: expected the token to be refused, got %v", name, err)
+		}
+	}
+
+	if _, err := a.Authenticate(request("Authorization", "Basic YWxpY2U6eA==")); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected other schemes to be left to other authenticators, got %v", err)
+	}
+}
+
+func TestJWTFromURL(t *testing.T) {
+	iss := newIssuer(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(iss.jwks)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, err := NewJWT(ctx, config.AuthJWT{JWKSURL: srv.URL, Issuer: "https://idp", Audience: "skill-api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := a.Authenticate(request("Authorization", "Bearer "+iss.token(t, claims(nil)))); err != nil || p.Subject != "alice" {
+		t.Errorf("Expected alice, got %+v, %v", p, err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	sum := sha256.Sum256([]byte("s3cret"))
+	a, err := NewAPIKeys([]string{"ci:editor:" + hex.EncodeToString(sum[:])})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := a.Authenticate(request(APIKeyHeader, "s3cret"))
+	if err != nil || p.Actor() != "apikey:ci" || !slices.Equal(p.Roles, []string{"editor"}) {
+		t.Errorf("Expected the ci key, got %+v, %v", p, err)
+	}
+	if _, err := a.Authenticate(request(APIKeyHeader, "guess")); err == nil || errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected an unknown key to be refused, got %v", err)
+	}
+	if _, err := a.Authenticate(request("", "")); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected no credentials, got %v", err)
+	}
+
+	for _, entry := range []string{"ci:editor:s3cret", "ci:" + hex.EncodeToString(sum[:]), "ci:owner:" + hex.EncodeToString(sum[:])} {
+		if _, err := NewAPIKeys([]string{entry}); err == nil {
+			t.Errorf("Expected %q to be refused", entry)
+		}
+	}
+}
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sum := sha256.Sum256([]byte("s3cret"))
+	keys := []string{"ci:editor:" + hex.EncodeToString(sum[:])}
+
+	for _, tc := range []struct {
+		name      string
+		anonymous bool
+		key       string
+		status    int
+		actor     string
+	}{
+		{"key", false, "s3cret", http.StatusOK, "apikey:ci"},
+		{"missing", false, "", http.StatusUnauthorized, ""},
+		{"wrong", false, "guess", http.StatusUnauthorized, ""},
+		{"anonymous", true, "", http.StatusOK, "anonymous"},
+		{"wrong with anonymous", true, "guess", http.StatusUnauthorized, ""},
+	} {
+		a, err := New(context.Background(), config.Auth{APIKeys: keys, Anonymous: tc.anonymous, AnonymousRole: "viewer"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var actor string
+		r := gin.New()
+		r.Use(Gin(a))
+		r.DELETE("/api/v1/skills/:key", func(c *gin.Context) {
+			p, _ := FromContext(c.Request.Context())
+			actor = p.Actor()
+		})
+
+		w := httptest.NewRecorder()
+		req := request("", "")
+		if tc.key != "" {
+			req.Header.Set(APIKeyHeader, tc.key)
+		}
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.status || actor != tc.actor {
+			t.Errorf("%s: expected %d as %q, got %d as %q", tc.name, tc.status, tc.actor, w.Code, actor)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected a WWW-Authenticate challenge", tc.name)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		roles  []string
+		perm   Permission
+		status int
+	}{
+		{[]string{"viewer"}, Read, http.StatusOK},
+		{[]string{"viewer"}, Write, http.StatusForbidden},
+		{[]string{"editor"}, Write, http.StatusOK},
+		{[]string{"editor"}, Admin, http.StatusForbidden},
+		{[]string{"viewer", "admin"}, Admin, http.StatusOK},
+		{nil, Read, http.StatusForbidden},
+	} {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), Principal{Subject: "alice", Method: "jwt", Roles: tc.roles}))
+		})
+		r.DELETE("/api/v1/skills/:key", Require(tc.perm), func(c *gin.Context) {})
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, request("", ""))
+		if w.Code != tc.status {
+			t.Errorf("%v needing %s: expected %d, got %d", tc.roles, tc.perm, tc.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/narunart-atise/skill-api-kafka/config"
+)
+
+// JWT authenticates requests carrying an "Authorization: Bearer" token.
+type JWT struct {
+	keys       keyfunc.Keyfunc
+	parser     *jwt.Parser
+	rolesClaim string
+}
+
+// NewJWT loads the JWK Set named by cfg. A set fetched from a URL is
+// refreshed in the background until ctx is cancelled, and fetched again when
+// a token names a key it does not hold.
+func NewJWT(ctx context.Context, cfg config.AuthJWT) (*JWT, error) {
+	var (
+		keys keyfunc.Keyfunc
+		err  error
+	)
+	if cfg.JWKSURL != "" {
+		keys, err = keyfunc.NewDefaultCtx(ctx, []string{cfg.JWKSURL})
+	} else {
+		var set []byte
+		if set, err = os.ReadFile(cfg.JWKSFile); err == nil {
+			keys, err = keyfunc.NewJWKSetJSON(set)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: load JWK Set: %w", err)
+	}
+
+	return &JWT{
+		keys:       keys,
+		rolesClaim: cfg.RolesClaim,
+		parser: jwt.NewParser(
+			// Only asymmetric algorithms: the keys are public.
+			jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+			jwt.WithIssuer(cfg.Issuer),
+			jwt.WithAudience(cfg.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(cfg.Leeway),
+		),
+	}, nil
+}
+
+func (j *JWT) Authenticate(r *http.Request) (Principal, error) {
+	scheme, raw, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || raw == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	var claims jwt.MapClaims
+	token, err := j.parser.ParseWithClaims(raw, &claims, j.keys.KeyfuncCtx(r.Context()))
+	if err != nil {
+		return Principal{}, fmt.Errorf("auth: %w", err)
+	}
+	subject, err := token.Claims.GetSubject()
+	if err != nil || subject == "" {
+		return Principal{}, errors.New("auth: token has no subject")
+	}
+	return Principal{Subject: subject, Method: "jwt", Roles: roles(claims[j.rolesClaim])}, nil
+}
+
+// roles picks the known roles out of a claim holding a list of names or a
+// space separated string of them, as scope claims do.
+func roles(claim any) []string {
+	var names []string
+	switch claim := claim.(type) {
+	case string:
+		names = strings.Fields(claim)
+	case []any:
+		for _, name := range claim {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var known []string
+	for _, name := range names {
+		if slices.Contains(config.Roles, name) {
+			known = append(known, name)
+		}
+	}
+	return known
+}
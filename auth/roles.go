@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permission is what a route requires of its caller.
+type Permission string
+
+const (
+	// Read lists and fetches skills and their history.
+	Read Permission = "read"
+	// Write creates, updates and restores skills.
+	Write Permission = "write"
+	// Admin deletes skills, also by reverting one to before it existed, and
+	// reverts whole events.
+	Admin Permission = "admin"
+)
+
+// permissions are granted by each of config.Roles.
+var permissions = map[string][]Permission{
+	"viewer": {Read},
+	"editor": {Read, Write},
+	"admin":  {Read, Write, Admin},
+}
+
+// Can reports whether one of the principal's roles grants perm.
+func (p Principal) Can(perm Permission) bool {
+	for _, role := range p.Roles {
+		if slices.Contains(permissions[role], perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Require rejects with 403 the requests of principals lacking perm. It runs
+// after Gin, which puts the principal in the request context.
+func Require(perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if Authorize(c, perm) {
+			c.Next()
+		}
+	}
+}
+
+// Authorize checks perm the way Require does, for handlers that only know
+// what they need once they have read the request. It rejects the request
+// with 403 and returns false when the principal lacks perm.
+func Authorize(c *gin.Context, perm Permission) bool {
+	p, _ := FromContext(c.Request.Context())
+	if !p.Can(perm) {
+		audit(c, "Access denied", "authorization_denied", p, slog.String("permission", string(perm)))
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "error", "message": "permission denied"})
+		return false
+	}
+	return true
+}
+
+// audit logs a refused request under an audit attribute naming the event, so
+// refusals can be told apart from the request log and alerted on.
+func audit(c *gin.Context, msg, event string, p Principal, attrs ...slog.Attr) {
+	attrs = append([]slog.Attr{
+		slog.String("audit", event),
+		slog.String("actor", p.Actor()),
+		slog.Any("roles", p.Roles),
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+		slog.String("route", c.FullPath()),
+	}, attrs...)
+	slog.LogAttrs(c.Request.Context(), slog.LevelWarn, msg, attrs...)
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"cmp"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Roles are what callers are allowed to do: viewers read skills, editors
+// also change them and admins also delete them and revert whole events.
+var Roles = []string{"viewer", "editor", "admin"}
+
+// Auth decides who may call the skill API. Callers present a JWT bearer token
+// or an API key; either is accepted when both are configured.
+type Auth struct {
+	JWT AuthJWT `yaml:"jwt"`
+	// APIKeys are name:role:hash triples, the hash being the hex SHA-256 of
+	// the key, so the keys themselves are never stored.
+	APIKeys []string `yaml:"api_keys" env:"AUTH_API_KEYS" flag:"auth-api-keys" usage:"comma separated API keys as name:role:sha256-hex" secret:"true"`
+	// Anonymous lets requests without credentials through as "anonymous",
+	// with AnonymousRole. Credentials that are presented must still be valid.
+	Anonymous     bool   `yaml:"anonymous" env:"AUTH_ANONYMOUS" flag:"auth-anonymous" usage:"accept requests without credentials"`
+	AnonymousRole string `yaml:"anonymous_role" env:"AUTH_ANONYMOUS_ROLE" flag:"auth-anonymous-role" usage:"role of requests without credentials: viewer, editor or admin"`
+}
+
+// AuthJWT verifies bearer tokens against the keys of a JWK Set read from a
+// file or fetched, and refreshed, from a URL.
+type AuthJWT struct {
+	JWKSFile string        `yaml:"jwks_file" env:"AUTH_JWT_JWKS_FILE" flag:"auth-jwt-jwks-file" usage:"JWK Set file verifying bearer tokens"`
+	JWKSURL  string        `yaml:"jwks_url" env:"AUTH_JWT_JWKS_URL" flag:"auth-jwt-jwks-url" usage:"JWK Set URL verifying bearer tokens"`
+	Issuer   string        `yaml:"issuer" env:"AUTH_JWT_ISSUER" flag:"auth-jwt-issuer" usage:"required iss claim"`
+	Audience string        `yaml:"audience" env:"AUTH_JWT_AUDIENCE" flag:"auth-jwt-audience" usage:"required aud claim"`
+	Leeway   time.Duration `yaml:"leeway" env:"AUTH_JWT_LEEWAY" flag:"auth-jwt-leeway" usage:"clock skew allowed when checking exp and nbf"`
+	// RolesClaim holds the caller's roles, as a list or a space separated
+	// string. Names other than the Roles are ignored.
+	RolesClaim string `yaml:"roles_claim" env:"AUTH_JWT_ROLES_CLAIM" flag:"auth-jwt-roles-claim" usage:"claim listing the caller's roles"`
+}
+
+// Enabled reports whether bearer tokens are accepted.
+func (j AuthJWT) Enabled() bool {
+	return j.JWKSFile != "" || j.JWKSURL != ""
+}
+
+func (a Auth) Validate() error {
+	var errs []error
+	if !a.JWT.Enabled() && len(a.APIKeys) == 0 && !a.Anonymous {
+		errs = append(errs, errors.New("auth needs auth.jwt or auth.api_keys, or auth.anonymous to run without authentication"))
+	}
+
+	if a.JWT.Enabled() {
+		if a.JWT.JWKSFile != "" && a.JWT.JWKSURL != "" {
+			errs = append(errs, errors.New("auth.jwt.jwks_file and auth.jwt.jwks_url are mutually exclusive"))
+		}
+		if a.JWT.JWKSURL != "" {
+			if u, err := url.Parse(a.JWT.JWKSURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, errors.New("auth.jwt.jwks_url must be an http or https URL"))
+			}
+		}
+		if a.JWT.Issuer == "" || a.JWT.Audience == "" {
+			errs = append(errs, errors.New("auth.jwt.issuer and auth.jwt.audience are required with a JWK Set"))
+		}
+		if a.JWT.Leeway < 0 {
+			errs = append(errs, errors.New("auth.jwt.leeway must not be negative"))
+		}
+		if a.JWT.RolesClaim == "" {
+			errs = append(errs, errors.New("auth.jwt.roles_claim is required with a JWK Set"))
+		}
+	}
+	if a.Anonymous && !slices.Contains(Roles, a.AnonymousRole) {
+		errs = append(errs, fmt.Errorf("auth.anonymous_role must be viewer, editor or admin, got %q", a.AnonymousRole))
+	}
+
+	names := map[string]bool{}
+	for i, key := range a.APIKeys {
+		parts := strings.Split(key, ":")
+		if len(parts) != 3 || parts[0] == "" || !slices.Contains(Roles, parts[1]) {
+			// The entry itself is not echoed, it may be a key pasted by mistake.
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d] must be name:role:sha256-hex with role viewer, editor or admin", i))
+			continue
+		}
+		name := parts[0]
+		if b, err := hex.DecodeString(parts[2]); err != nil || len(b) != 32 {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d] must end in a hex SHA-256", i))
+			continue
+		}
+		if names[name] {
+			errs = append(errs, fmt.Errorf("auth.api_keys names a key %q twice", name))
+		}
+		names[name] = true
+	}
+	return errors.Join(errs...)
+}
+
+// defaultAuth lets anonymous callers in with anonymousRole when it is set.
+func defaultAuth(anonymousRole string) Auth {
+	return Auth{
+		JWT:           AuthJWT{Leeway: 30 * time.Second, RolesClaim: "roles"},
+		Anonymous:     anonymousRole != "",
+		AnonymousRole: cmp.Or(anonymousRole, "viewer"),
+	}
+}
@@ -0,0 +1,249 @@
+// Package config loads the typed configuration of the API and the consumer
+// from defaults, an optional YAML file, environment variables and flags, in
+// that order of precedence.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type HTTP struct {
+	Port            string        `yaml:"port" env:"PORT" flag:"port" usage:"HTTP listen port"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" flag:"http-shutdown-timeout" usage:"time allowed for in-flight requests on shutdown"`
+}
+
+func (h HTTP) Validate() error {
+	if port, err := strconv.Atoi(h.Port); err != nil || port < 1 || port > 65535 {
+		return errors.New("http.port must be a number between 1 and 65535")
+	}
+	return nil
+}
+
+type Purge struct {
+	Retention time.Duration `yaml:"retention" env:"PURGE_RETENTION" flag:"purge-retention" usage:"hard-delete skills this long after a soft delete, 0 keeps them forever"`
+	Interval  time.Duration `yaml:"interval" env:"PURGE_INTERVAL" flag:"purge-interval" usage:"how often the purge job runs"`
+}
+
+func (p Purge) Validate() error {
+	if p.Retention < 0 {
+		return errors.New("purge.retention must not be negative")
+	}
+	if p.Retention > 0 && p.Interval <= 0 {
+		return errors.New("purge.interval must be positive when purge.retention is set")
+	}
+	return nil
+}
+
+// Health tunes the readiness checks behind /readyz.
+type Health struct {
+	Timeout time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT" flag:"health-timeout" usage:"time allowed for the readiness checks"`
+	// MaxLag is only used by the consumer.
+	MaxLag int64 `yaml:"max_lag" env:"HEALTH_MAX_LAG" flag:"health-max-lag" usage:"report the consumer unready when more messages than this wait to be consumed, 0 for no limit"`
+}
+
+func (h Health) Validate() error {
+	if h.Timeout <= 0 {
+		return errors.New("health.timeout must be positive")
+	}
+	if h.MaxLag < 0 {
+		return errors.New("health.max_lag must not be negative")
+	}
+	return nil
+}
+
+func defaultHealth() Health {
+	return Health{Timeout: 2 * time.Second}
+}
+
+// Tracing exports OpenTelemetry spans following a command from the API
+// request through the broker to the consumer's database write.
+type Tracing struct {
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" usage:"span exporter: none, stdout or otlp"`
+	Endpoint string `yaml:"endpoint" env:"TRACING_ENDPOINT" flag:"tracing-endpoint" usage:"OTLP/HTTP collector URL, http:// to send without TLS"`
+}
+
+func (t Tracing) Validate() error {
+	switch t.Exporter {
+	case "none", "stdout":
+		return nil
+	case "otlp":
+		if u, err := url.Parse(t.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("tracing.endpoint must be an http or https URL")
+		}
+		return nil
+	default:
+		return fmt.Errorf("tracing.exporter must be none, stdout or otlp, got %q", t.Exporter)
+	}
+}
+
+func defaultTracing() Tracing {
+	return Tracing{Exporter: "none", Endpoint: "http://localhost:4318"}
+}
+
+// Log configures the structured logs written to stderr.
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"lowest level logged: debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"log format: json or text"`
+	// Payloads decides how much of each consumed message is logged: none,
+	// redacted, with the Redact fields masked, or full.
+	Payloads string   `yaml:"payloads" env:"LOG_PAYLOADS" flag:"log-payloads" usage:"message payload logging: none, redacted or full"`
+	Redact   []string `yaml:"redact" env:"LOG_REDACT" flag:"log-redact" usage:"payload fields masked when payloads are redacted"`
+}
+
+func (l Log) Validate() error {
+	var errs []error
+	switch l.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", l.Level))
+	}
+	if l.Format != "json" && l.Format != "text" {
+		errs = append(errs, fmt.Errorf("log.format must be json or text, got %q", l.Format))
+	}
+	switch l.Payloads {
+	case "none", "redacted", "full":
+	default:
+		errs = append(errs, fmt.Errorf("log.payloads must be none, redacted or full, got %q", l.Payloads))
+	}
+	return errors.Join(errs...)
+}
+
+func defaultLog() Log {
+	return Log{Level: "info", Format: "json", Payloads: "redacted", Redact: []string{"actor", "data"}}
+}
+
+type API struct {
+	HTTP        HTTP        `yaml:"http"`
+	Database    Database    `yaml:"database"`
+	Transport   Transport   `yaml:"transport"`
+	Kafka       Kafka       `yaml:"kafka"`
+	Health      Health      `yaml:"health"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Log         Log         `yaml:"log"`
+	Auth        Auth        `yaml:"auth"`
+	RateLimit   RateLimit   `yaml:"rate_limit"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	// CheckExistence answers 404 or 409 before publishing a command for a
+	// skill that is missing or already exists. The state read may be stale,
+	// so the consumer checks again when it applies the command.
+	CheckExistence bool `yaml:"check_existence" env:"CHECK_EXISTENCE" flag:"check-existence" usage:"refuse commands for missing or existing skills before publishing them"`
+}
+
+// LoadAPI loads and validates the API configuration. It returns the command
+// line arguments left after the flags, e.g. a subcommand.
+func LoadAPI(args []string) (API, []string, error) {
+	cfg := API{
+		HTTP:        HTTP{Port: "9810", ShutdownTimeout: 5 * time.Second},
+		Database:    defaultDatabase("postgres", ""),
+		Transport:   defaultTransport(),
+		Kafka:       defaultKafka("skill-api"),
+		Health:      defaultHealth(),
+		Tracing:     defaultTracing(),
+		Log:         defaultLog(),
+		Auth:        defaultAuth(""),
+		RateLimit:   defaultRateLimit(),
+		Idempotency: defaultIdempotency(),
+
+		CheckExistence: true,
+	}
+
+	rest, err := load("api", &cfg, args)
+	if err != nil {
+		return API{}, nil, err
+	}
+
+	return cfg, rest, errors.Join(cfg.HTTP.Validate(), cfg.Database.validateShared(), cfg.Transport.Validate(cfg.Kafka), cfg.Health.Validate(), cfg.Tracing.Validate(), cfg.Log.Validate(), cfg.Auth.Validate(), cfg.RateLimit.Validate(cfg.Database), cfg.Idempotency.Validate(cfg.Database))
+}
+
+type Consumer struct {
+	// HTTP serves the health checks and debug variables.
+	HTTP        HTTP      `yaml:"http"`
+	Database    Database  `yaml:"database"`
+	Transport   Transport `yaml:"transport"`
+	Kafka       Kafka     `yaml:"kafka"`
+	Purge       Purge     `yaml:"purge"`
+	Health      Health    `yaml:"health"`
+	Tracing     Tracing   `yaml:"tracing"`
+	Log         Log       `yaml:"log"`
+	AutoMigrate bool      `yaml:"auto_migrate" env:"AUTO_MIGRATE" flag:"auto-migrate" usage:"apply pending migrations on startup"`
+	// DrainTimeout bounds how long the message being handled at shutdown
+	// may take to finish before it is abandoned and delivered again later.
+	DrainTimeout time.Duration `yaml:"drain_timeout" env:"CONSUMER_DRAIN_TIMEOUT" flag:"drain-timeout" usage:"time allowed for the message in hand to be handled on shutdown"`
+}
+
+// LoadConsumer loads and validates the consumer configuration. It returns the
+// command line arguments left after the flags, e.g. a subcommand.
+func LoadConsumer(args []string) (Consumer, []string, error) {
+	cfg := Consumer{
+		HTTP:      HTTP{Port: "9811", ShutdownTimeout: 5 * time.Second},
+		Database:  defaultDatabase("postgres", ""),
+		Transport: defaultTransport(),
+		Kafka:     defaultKafka("skill-consumer"),
+		Purge:     Purge{Interval: time.Hour},
+		Health:    defaultHealth(),
+		Tracing:   defaultTracing(),
+		Log:       defaultLog(),
+
+		DrainTimeout: 10 * time.Second,
+	}
+
+	rest, err := load("consumer", &cfg, args)
+	if err != nil {
+		return Consumer{}, nil, err
+	}
+
+	return cfg, rest, errors.Join(cfg.HTTP.Validate(), cfg.Database.validateShared(), cfg.Transport.Validate(cfg.Kafka), cfg.Purge.Validate(), cfg.Health.Validate(), cfg.Tracing.Validate(), cfg.Log.Validate(), validateDrainTimeout(cfg.DrainTimeout))
+}
+
+// Dev runs the API and the consumer in one process with no external services.
+type Dev struct {
+	HTTP     HTTP     `yaml:"http"`
+	Database Database `yaml:"database"`
+	Health   Health   `yaml:"health"`
+	Tracing  Tracing  `yaml:"tracing"`
+	Log      Log      `yaml:"log"`
+	Auth     Auth     `yaml:"auth"`
+	// RateLimit is off by default, so scripts run against dev are not slowed.
+	RateLimit   RateLimit   `yaml:"rate_limit"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	Fixtures    string      `yaml:"fixtures" env:"DEV_FIXTURES" flag:"fixtures" usage:"fixture directory loaded on startup, empty to start with no skills"`
+
+	CheckExistence bool          `yaml:"check_existence" env:"CHECK_EXISTENCE" flag:"check-existence" usage:"refuse commands for missing or existing skills before publishing them"`
+	DrainTimeout   time.Duration `yaml:"drain_timeout" env:"CONSUMER_DRAIN_TIMEOUT" flag:"drain-timeout" usage:"time allowed for the message in hand to be handled on shutdown"`
+}
+
+// LoadDev loads and validates the dev mode configuration.
+func LoadDev(args []string) (Dev, []string, error) {
+	cfg := Dev{
+		HTTP:        HTTP{Port: "9810", ShutdownTimeout: 5 * time.Second},
+		Database:    defaultDatabase("sqlite", "skill-dev.db"),
+		Health:      defaultHealth(),
+		Tracing:     defaultTracing(),
+		Log:         defaultLog(),
+		Auth:        defaultAuth("admin"),
+		RateLimit:   RateLimit{Store: "memory", Period: time.Minute},
+		Idempotency: defaultIdempotency(),
+		Fixtures:    "../fixtures/dev",
+
+		CheckExistence: true,
+		DrainTimeout:   10 * time.Second,
+	}
+
+	rest, err := load("dev", &cfg, args)
+	if err != nil {
+		return Dev{}, nil, err
+	}
+
+	return cfg, rest, errors.Join(cfg.HTTP.Validate(), cfg.Database.Validate(), cfg.Health.Validate(), cfg.Tracing.Validate(), cfg.Log.Validate(), cfg.Auth.Validate(), cfg.RateLimit.Validate(cfg.Database), cfg.Idempotency.Validate(cfg.Database), validateDrainTimeout(cfg.DrainTimeout))
+}
+
+func validateDrainTimeout(d time.Duration) error {
+	if d <= 0 {
+		return errors.New("drain_timeout must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "api.yaml")
+	yamlConfig := `
+http:
+  port: "7000"
+database:
+  url: postgres://file
+kafka:
+  brokers: [file-1:9092]
+  topic: file-topic
+  producer:
+    retry_backoff: 2s
+auth:
+  anonymous: true
+`
+	if err := os.WriteFile(file, []byte(yamlConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "postgres://env")
+	t.Setenv("BROKER", "legacy:9092")
+	t.Setenv("KAFKA_BROKERS", "env-1:9092, env-2:9092")
+
+	cfg, rest, err := LoadAPI([]string{"-config", file, "-port", "7001", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("LoadAPI error: %v", err)
+	}
+
+	if cfg.HTTP.Port != "7001" {
+		t.Errorf("Expected flag to win, got port %s", cfg.HTTP.Port)
+	}
+	if cfg.Database.URL != "postgres://env" {
+		t.Errorf("Expected env to win over the file, got %s", cfg.Database.URL)
+	}
+	if !reflect.DeepEqual(cfg.Kafka.Brokers, []string{"env-1:9092", "env-2:9092"}) {
+		t.Errorf("Expected KAFKA_BROKERS to win over BROKER, got %v", cfg.Kafka.Brokers)
+	}
+	if cfg.Kafka.Topic != "file-topic" || cfg.Kafka.Producer.RetryBackoff != 2*time.Second {
+		t.Errorf("Expected file values, got topic %s backoff %s", cfg.Kafka.Topic, cfg.Kafka.Producer.RetryBackoff)
+	}
+	if cfg.Kafka.Producer.RequiredAcks != "all" {
+		t.Errorf("Expected defaults to survive, got acks %s", cfg.Kafka.Producer.RequiredAcks)
+	}
+	if !reflect.DeepEqual(rest, []string{"migrate", "up"}) {
+		t.Errorf("Expected subcommand args, got %v", rest)
+	}
+}
+
+func TestLoadValidates(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("KAFKA_PRODUCER_REQUIRED_ACKS", "some")
+	t.Setenv("CONSUMER_DRAIN_TIMEOUT", "0s")
+
+	_, _, err := LoadConsumer(nil)
+	if err == nil {
+		t.Fatal("Expected a validation error")
+	}
+	for _, want := range []string{"database.url", "required_acks", "drain_timeout"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadDatabaseDriver(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "")
+	t.Setenv("DATABASE_DRIVER", "memory")
+
+	if _, _, err := LoadAPI(nil); err == nil || !strings.Contains(err.Error(), "dev mode") {
+		t.Errorf("Expected the memory driver to be refused outside dev mode, got %v", err)
+	}
+
+	cfg, _, err := LoadDev(nil)
+	if err != nil {
+		t.Fatalf("LoadDev error: %v", err)
+	}
+	if cfg.Database.Driver != "memory" {
+		t.Errorf("Expected driver memory, got %s", cfg.Database.Driver)
+	}
+
+	t.Setenv("DATABASE_DRIVER", "mysql")
+	if _, _, err := LoadDev(nil); err == nil || !strings.Contains(err.Error(), "database.driver") {
+		t.Errorf("Expected an unknown driver to be refused, got %v", err)
+	}
+}
+
+func TestLoadDatabasePool(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "postgres://env")
+	t.Setenv("DATABASE_POOL_MAX_OPEN", "4")
+
+	cfg, _, err := LoadConsumer(nil)
+	if err == nil || !strings.Contains(err.Error(), "max_idle") {
+		t.Errorf("Expected the default max_idle above max_open to be refused, got %v", err)
+	}
+
+	t.Setenv("DATABASE_POOL_MAX_IDLE", "2")
+	if cfg, _, err = LoadConsumer(nil); err != nil {
+		t.Fatalf("LoadConsumer error: %v", err)
+	}
+	if cfg.Database.Pool.MaxOpen != 4 || cfg.Database.Pool.MaxLifetime != 30*time.Minute || cfg.Database.ConnectAttempts != 10 {
+		t.Errorf("Expected env and defaults to combine, got %+v", cfg.Database)
+	}
+}
+
+func TestLoadHealth(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "postgres://env")
+	t.Setenv("HEALTH_MAX_LAG", "500")
+
+	cfg, _, err := LoadConsumer(nil)
+	if err != nil {
+		t.Fatalf("LoadConsumer error: %v", err)
+	}
+	if cfg.HTTP.Port != "9811" || cfg.Health.MaxLag != 500 || cfg.Health.Timeout != 2*time.Second {
+		t.Errorf("Expected the consumer port, env lag and default timeout, got %+v %+v", cfg.HTTP, cfg.Health)
+	}
+
+	t.Setenv("HEALTH_TIMEOUT", "0s")
+	if _, _, err := LoadConsumer(nil); err == nil || !strings.Contains(err.Error(), "health.timeout") {
+		t.Errorf("Expected a zero timeout to be refused, got %v", err)
+	}
+}
+
+func TestLoadTracing(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("TRACING_EXPORTER", "otlp")
+	t.Setenv("TRACING_ENDPOINT", "collector:4318")
+
+	if _, _, err := LoadDev(nil); err == nil || !strings.Contains(err.Error(), "tracing.endpoint") {
+		t.Errorf("Expected an endpoint without a scheme to be refused, got %v", err)
+	}
+
+	t.Setenv("TRACING_ENDPOINT", "https://collector:4318")
+	if _, _, err := LoadDev(nil); err != nil {
+		t.Errorf("LoadDev error: %v", err)
+	}
+}
+
+func TestLoadLog(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LOG_REDACT", "actor")
+
+	cfg, _, err := LoadDev(nil)
+	if err != nil {
+		t.Fatalf("LoadDev error: %v", err)
+	}
+	if cfg.Log.Level != "info" || cfg.Log.Payloads != "redacted" || !reflect.DeepEqual(cfg.Log.Redact, []string{"actor"}) {
+		t.Errorf("Expected defaults and the env redact list, got %+v", cfg.Log)
+	}
+
+	t.Setenv("LOG_LEVEL", "trace")
+	t.Setenv("LOG_PAYLOADS", "some")
+	_, _, err = LoadDev(nil)
+	for _, want := range []string{"log.level", "log.payloads"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadAuth(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "postgres://env")
+	hash := strings.Repeat("ab", 32)
+
+	if _, _, err := LoadAPI(nil); err == nil || !strings.Contains(err.Error(), "auth.anonymous") {
+		t.Errorf("Expected the API to refuse to run without authentication, got %v", err)
+	}
+
+	t.Setenv("AUTH_API_KEYS", "ci:editor:"+hash+",ci:viewer:"+hash+",plain-key,ops:root:"+hash)
+	t.Setenv("AUTH_JWT_JWKS_URL", "https://idp/.well-known/jwks.json")
+	_, _, err := LoadAPI(nil)
+	for _, want := range []string{"api_keys[2]", "api_keys[3]", "twice", "issuer"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got %v", want, err)
+		}
+	}
+	if err != nil && strings.Contains(err.Error(), "plain-key") {
+		t.Errorf("Expected the malformed entry not to be echoed, got %v", err)
+	}
+
+	t.Setenv("AUTH_API_KEYS", "ci:editor:"+hash)
+	t.Setenv("AUTH_JWT_ISSUER", "https://idp")
+	t.Setenv("AUTH_JWT_AUDIENCE", "skill-api")
+	cfg, _, err := LoadAPI(nil)
+	if err != nil {
+		t.Fatalf("LoadAPI error: %v", err)
+	}
+	if cfg.Auth.JWT.Leeway != 30*time.Second || cfg.Auth.JWT.RolesClaim != "roles" || cfg.Auth.Anonymous {
+		t.Errorf("Expected the default leeway and roles claim and no anonymous access, got %+v", cfg.Auth)
+	}
+	if out := Redacted(cfg); strings.Contains(out, hash) {
+		t.Errorf("Expected the key hashes to be redacted, got\n%s", out)
+	}
+
+	t.Setenv("AUTH_ANONYMOUS", "true")
+	t.Setenv("AUTH_ANONYMOUS_ROLE", "root")
+	if _, _, err := LoadAPI(nil); err == nil || !strings.Contains(err.Error(), "anonymous_role") {
+		t.Errorf("Expected an unknown anonymous role to be refused, got %v", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	for _, tc := range []struct {
+		url, kept string
+	}{
+		{"postgres://user:hunter2@db:5432/app", "postgres://user:xxxxx@db:5432/app"},
+		{"postgres://user@db:5432/app?sslmode=disable&password=hunter2", "sslmode=disable"},
+		{"postgres://db/app?sslpassword=hunter2", "postgres://db/app"},
+		{"host=db user=postgres password=hunter2 dbname=app", mask},
+		{"db.example.com:5432", mask},
+	} {
+		out := Redacted(API{Database: Database{URL: tc.url}})
+		if strings.Contains(out, "hunter2") {
+			t.Errorf("Expected the password in %s to be redacted, got\n%s", tc.url, out)
+		}
+		if !strings.Contains(out, tc.kept) {
+			t.Errorf("Expected %s to keep %s, got\n%s", tc.url, tc.kept, out)
+		}
+	}
+}
+
+func TestLoadRateLimit(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "postgres://env")
+	t.Setenv("AUTH_ANONYMOUS", "true")
+
+	cfg, _, err := LoadAPI(nil)
+	if err != nil {
+		t.Fatalf("LoadAPI error: %v", err)
+	}
+	if want := (RateLimit{Store: "memory", Read: 600, Write: 60, Failures: 10, Period: time.Minute}); cfg.RateLimit != want {
+		t.Errorf("Expected %+v, got %+v", want, cfg.RateLimit)
+	}
+
+	t.Setenv("RATE_LIMIT_STORE", "redis")
+	t.Setenv("RATE_LIMIT_WRITE", "-1")
+	t.Setenv("RATE_LIMIT_PERIOD", "0s")
+	_, _, err = LoadAPI(nil)
+	for _, want := range []string{"rate_limit.store", "negative", "rate_limit.period"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got %v", want, err)
+		}
+	}
+
+	t.Setenv("RATE_LIMIT_STORE", "database")
+	t.Setenv("RATE_LIMIT_WRITE", "10")
+	t.Setenv("DATABASE_DRIVER", "memory")
+	if _, _, err := LoadDev(nil); err == nil || !strings.Contains(err.Error(), "rate_limit.store database") {
+		t.Errorf("Expected the database store to need a database, got %v", err)
+	}
+}
+
+func TestLoadIdempotency(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "postgres://env")
+	t.Setenv("AUTH_ANONYMOUS", "true")
+
+	cfg, _, err := LoadAPI(nil)
+	if err != nil {
+		t.Fatalf("LoadAPI error: %v", err)
+	}
+	if want := (Idempotency{Store: "memory", TTL: 24 * time.Hour}); cfg.Idempotency != want {
+		t.Errorf("Expected %+v, got %+v", want, cfg.Idempotency)
+	}
+
+	t.Setenv("IDEMPOTENCY_STORE", "redis")
+	t.Setenv("IDEMPOTENCY_TTL", "0s")
+	_, _, err = LoadAPI(nil)
+	for _, want := range []string{"idempotency.store", "idempotency.ttl"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadCheckExistence(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "postgres://env")
+	t.Setenv("AUTH_ANONYMOUS", "true")
+
+	cfg, _, err := LoadAPI(nil)
+	if err != nil || !cfg.CheckExistence {
+		t.Errorf("Expected existence checks by default, got %v, %v", cfg.CheckExistence, err)
+	}
+
+	t.Setenv("CHECK_EXISTENCE", "false")
+	if cfg, _, err = LoadAPI(nil); err != nil || cfg.CheckExistence {
+		t.Errorf("Expected CHECK_EXISTENCE to turn the checks off, got %v, %v", cfg.CheckExistence, err)
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Database struct {
+	Driver string       `yaml:"driver" env:"DATABASE_DRIVER" flag:"database-driver" usage:"storage backend: postgres, sqlite or memory"`
+	URL    string       `yaml:"url" env:"DATABASE_URL" flag:"database-url" usage:"Postgres connection URL or SQLite file" secret:"url"`
+	Pool   DatabasePool `yaml:"pool"`
+
+	// Replicas are only used by the API, for reads.
+	Replicas DatabaseReplicas `yaml:"replicas"`
+
+	ConnectAttempts int           `yaml:"connect_attempts" env:"DATABASE_CONNECT_ATTEMPTS" flag:"database-connect-attempts" usage:"times to try reaching the database on startup"`
+	ConnectBackoff  time.Duration `yaml:"connect_backoff" env:"DATABASE_CONNECT_BACKOFF" flag:"database-connect-backoff" usage:"wait after the first failed connect attempt, doubled after each one up to 10s"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" env:"DATABASE_CONNECT_TIMEOUT" flag:"database-connect-timeout" usage:"timeout for each connect attempt"`
+	StatsInterval   time.Duration `yaml:"stats_interval" env:"DATABASE_STATS_INTERVAL" flag:"database-stats-interval" usage:"how often to log connection pool statistics, 0 to never log them"`
+}
+
+// DatabasePool tunes the connection pool. SQLite always uses a single
+// connection and ignores it.
+type DatabasePool struct {
+	MaxOpen     int           `yaml:"max_open" env:"DATABASE_POOL_MAX_OPEN" flag:"database-pool-max-open" usage:"maximum open connections, 0 for no limit"`
+	MaxIdle     int           `yaml:"max_idle" env:"DATABASE_POOL_MAX_IDLE" flag:"database-pool-max-idle" usage:"maximum idle connections kept for reuse"`
+	MaxLifetime time.Duration `yaml:"max_lifetime" env:"DATABASE_POOL_MAX_LIFETIME" flag:"database-pool-max-lifetime" usage:"close connections older than this, 0 to keep them"`
+	MaxIdleTime time.Duration `yaml:"max_idle_time" env:"DATABASE_POOL_MAX_IDLE_TIME" flag:"database-pool-max-idle-time" usage:"close connections idle for longer than this, 0 to keep them"`
+}
+
+// DatabaseReplicas are Postgres read replicas the API spreads its reads over
+// round-robin. Replicas failing their health check are skipped, and reads go
+// to the primary when none is left.
+type DatabaseReplicas struct {
+	URLs          []string      `yaml:"urls" env:"DATABASE_REPLICA_URLS" flag:"database-replica-urls" usage:"comma separated read replica connection URLs" secret:"url"`
+	CheckInterval time.Duration `yaml:"check_interval" env:"DATABASE_REPLICA_CHECK_INTERVAL" flag:"database-replica-check-interval" usage:"how often replicas are health checked"`
+	MaxStaleness  time.Duration `yaml:"max_staleness" env:"DATABASE_REPLICA_MAX_STALENESS" flag:"database-replica-max-staleness" usage:"skip replicas lagging further behind the primary when listing skills, 0 for no limit"`
+}
+
+func defaultDatabase(driver, url string) Database {
+	return Database{
+		Driver: driver,
+		URL:    url,
+		Pool: DatabasePool{
+			MaxOpen:     10,
+			MaxIdle:     5,
+			MaxLifetime: 30 * time.Minute,
+			MaxIdleTime: 5 * time.Minute,
+		},
+		Replicas: DatabaseReplicas{
+			CheckInterval: 5 * time.Second,
+		},
+		ConnectAttempts: 10,
+		ConnectBackoff:  500 * time.Millisecond,
+		ConnectTimeout:  5 * time.Second,
+	}
+}
+
+func (d Database) Validate() error {
+	var errs []error
+	switch d.Driver {
+	case "postgres", "sqlite":
+		if d.URL == "" {
+			errs = append(errs, errors.New("database.url is required"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("database.driver must be postgres, sqlite or memory, got %q", d.Driver))
+	}
+
+	if d.ConnectAttempts < 1 {
+		errs = append(errs, errors.New("database.connect_attempts must be at least 1"))
+	}
+	if d.ConnectBackoff <= 0 || d.ConnectTimeout <= 0 {
+		errs = append(errs, errors.New("database.connect_backoff and database.connect_timeout must be positive"))
+	}
+	if d.StatsInterval < 0 {
+		errs = append(errs, errors.New("database.stats_interval must not be negative"))
+	}
+	if err := d.Pool.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(d.Replicas.URLs) > 0 {
+		if d.Driver != "postgres" {
+			errs = append(errs, errors.New("database.replicas need the postgres driver"))
+		}
+		if d.Replicas.CheckInterval <= 0 || d.Replicas.MaxStaleness < 0 {
+			errs = append(errs, errors.New("database.replicas.check_interval must be positive and max_staleness not negative"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateShared validates a database the API and the consumer reach from
+// separate processes, which rules out the in-memory driver.
+func (d Database) validateShared() error {
+	if d.Driver == "memory" {
+		return errors.New("database.driver memory is only available in dev mode")
+	}
+	return d.Validate()
+}
+
+func (p DatabasePool) validate() error {
+	if p.MaxOpen < 0 || p.MaxIdle < 0 || p.MaxLifetime < 0 || p.MaxIdleTime < 0 {
+		return errors.New("database.pool settings must not be negative")
+	}
+	if p.MaxOpen > 0 && p.MaxIdle > p.MaxOpen {
+		return errors.New("database.pool.max_idle must not exceed database.pool.max_open")
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Idempotency keeps the responses of write requests sent with an
+// Idempotency-Key header, so a client retrying one gets the first response
+// back instead of publishing the command twice.
+type Idempotency struct {
+	// Store keeps the responses in memory, per replica, or in the database,
+	// shared by every replica of the API.
+	Store string        `yaml:"store" env:"IDEMPOTENCY_STORE" flag:"idempotency-store" usage:"where responses are kept: memory or database"`
+	TTL   time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" flag:"idempotency-ttl" usage:"how long an idempotency key and its response are kept"`
+}
+
+// Validate checks i against the database it may keep its responses in.
+func (i Idempotency) Validate(db Database) error {
+	var errs []error
+	switch i.Store {
+	case "memory":
+	case "database":
+		if db.Driver == "memory" {
+			errs = append(errs, errors.New("idempotency.store database needs a postgres or sqlite database"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("idempotency.store must be memory or database, got %q", i.Store))
+	}
+	if i.TTL <= 0 {
+		errs = append(errs, errors.New("idempotency.ttl must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+func defaultIdempotency() Idempotency {
+	return Idempotency{Store: "memory", TTL: 24 * time.Hour}
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+type Kafka struct {
+	Brokers      []string      `yaml:"brokers" env:"KAFKA_BROKERS,BROKER" flag:"kafka-brokers" usage:"comma separated list of Kafka brokers"`
+	Topic        string        `yaml:"topic" env:"KAFKA_TOPIC,TOPIC" flag:"kafka-topic" usage:"topic carrying skill commands"`
+	ClientID     string        `yaml:"client_id" env:"KAFKA_CLIENT_ID" flag:"kafka-client-id" usage:"client ID reported to the brokers"`
+	Version      string        `yaml:"version" env:"KAFKA_VERSION" flag:"kafka-version" usage:"Kafka protocol version, e.g. 2.1.0"`
+	DialTimeout  time.Duration `yaml:"dial_timeout" env:"KAFKA_DIAL_TIMEOUT" flag:"kafka-dial-timeout" usage:"timeout for connecting to a broker"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"KAFKA_READ_TIMEOUT" flag:"kafka-read-timeout" usage:"timeout for broker responses"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"KAFKA_WRITE_TIMEOUT" flag:"kafka-write-timeout" usage:"timeout for broker requests"`
+
+	TLS      KafkaTLS      `yaml:"tls"`
+	SASL     KafkaSASL     `yaml:"sasl"`
+	Topics   KafkaTopics   `yaml:"topics"`
+	Producer KafkaProducer `yaml:"producer"`
+	Consumer KafkaConsumer `yaml:"consumer"`
+
+	// TokenProvider supplies OAUTHBEARER tokens in place of the configured
+	// ones. It can only be set from code.
+	TokenProvider sarama.AccessTokenProvider `yaml:"-"`
+}
+
+type KafkaProducer struct {
+	RequiredAcks    string        `yaml:"required_acks" env:"KAFKA_PRODUCER_REQUIRED_ACKS" flag:"kafka-producer-required-acks" usage:"all, local or none"`
+	Timeout         time.Duration `yaml:"timeout" env:"KAFKA_PRODUCER_TIMEOUT" flag:"kafka-producer-timeout" usage:"how long the broker waits for required acks"`
+	Partitioner     string        `yaml:"partitioner" env:"KAFKA_PRODUCER_PARTITIONER" flag:"kafka-producer-partitioner" usage:"hash, roundrobin or random"`
+	Compression     string        `yaml:"compression" env:"KAFKA_PRODUCER_COMPRESSION" flag:"kafka-producer-compression" usage:"none, gzip, snappy, lz4 or zstd"`
+	MaxMessageBytes int           `yaml:"max_message_bytes" env:"KAFKA_PRODUCER_MAX_MESSAGE_BYTES" flag:"kafka-producer-max-message-bytes" usage:"largest message the producer will send"`
+	RetryMax        int           `yaml:"retry_max" env:"KAFKA_PRODUCER_RETRY_MAX" flag:"kafka-producer-retry-max" usage:"send retries before giving up"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff" env:"KAFKA_PRODUCER_RETRY_BACKOFF" flag:"kafka-producer-retry-backoff" usage:"wait between send retries"`
+	Idempotent      bool          `yaml:"idempotent" env:"KAFKA_PRODUCER_IDEMPOTENT" flag:"kafka-producer-idempotent" usage:"enable the idempotent producer"`
+}
+
+type KafkaConsumer struct {
+	Group             string        `yaml:"group" env:"KAFKA_CONSUMER_GROUP" flag:"kafka-consumer-group" usage:"consumer group shared by consumer instances"`
+	InitialOffset     string        `yaml:"initial_offset" env:"KAFKA_CONSUMER_INITIAL_OFFSET" flag:"kafka-consumer-initial-offset" usage:"oldest or newest"`
+	FetchMin          int32         `yaml:"fetch_min" env:"KAFKA_CONSUMER_FETCH_MIN" flag:"kafka-consumer-fetch-min" usage:"minimum bytes per fetch"`
+	FetchDefault      int32         `yaml:"fetch_default" env:"KAFKA_CONSUMER_FETCH_DEFAULT" flag:"kafka-consumer-fetch-default" usage:"default bytes per fetch"`
+	FetchMax          int32         `yaml:"fetch_max" env:"KAFKA_CONSUMER_FETCH_MAX" flag:"kafka-consumer-fetch-max" usage:"maximum bytes per fetch, 0 for no limit"`
+	MaxWaitTime       time.Duration `yaml:"max_wait_time" env:"KAFKA_CONSUMER_MAX_WAIT_TIME" flag:"kafka-consumer-max-wait-time" usage:"how long the broker may wait to fill fetch_min"`
+	MaxProcessingTime time.Duration `yaml:"max_processing_time" env:"KAFKA_CONSUMER_MAX_PROCESSING_TIME" flag:"kafka-consumer-max-processing-time" usage:"time a message may take before fetching pauses"`
+}
+
+func defaultKafka(clientID string) Kafka {
+	return Kafka{
+		Brokers:      []string{"localhost:29092"},
+		Topic:        "skill",
+		ClientID:     clientID,
+		Version:      sarama.DefaultVersion.String(),
+		DialTimeout:  30 * time.Second,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		Topics: KafkaTopics{
+			DeadLetter:        "skill.dlq",
+			Result:            "skill.result",
+			Partitions:        1,
+			ReplicationFactor: 1,
+			Retention:         7 * 24 * time.Hour,
+			CleanupPolicy:     "delete",
+		},
+		Producer: KafkaProducer{
+			RequiredAcks:    "all",
+			Timeout:         10 * time.Second,
+			Partitioner:     "hash",
+			Compression:     "none",
+			MaxMessageBytes: 1000000,
+			RetryMax:        3,
+			RetryBackoff:    100 * time.Millisecond,
+		},
+		Consumer: KafkaConsumer{
+			Group:             "skill-consumer",
+			InitialOffset:     "oldest",
+			FetchMin:          1,
+			FetchDefault:      1024 * 1024,
+			MaxWaitTime:       500 * time.Millisecond,
+			MaxProcessingTime: 100 * time.Millisecond,
+		},
+	}
+}
+
+func (k Kafka) Validate() error {
+	var errs []error
+	if len(k.Brokers) == 0 {
+		errs = append(errs, errors.New("kafka.brokers is required"))
+	}
+	if k.Topic == "" {
+		errs = append(errs, errors.New("kafka.topic is required"))
+	}
+	if err := k.Topics.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	config, err := k.Sarama()
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	return nil
+}
+
+// Sarama builds the client configuration shared by producers and consumers.
+func (k Kafka) Sarama() (*sarama.Config, error) {
+	config := sarama.NewConfig()
+	config.ClientID = k.ClientID
+	config.Net.DialTimeout = k.DialTimeout
+	config.Net.ReadTimeout = k.ReadTimeout
+	config.Net.WriteTimeout = k.WriteTimeout
+
+	if k.TLS.Enabled {
+		tlsConfig, err := k.TLS.config()
+		if err != nil {
+			return nil, err
+		}
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+
+	if err := k.SASL.apply(config, k.TokenProvider); err != nil {
+		return nil, err
+	}
+
+	version, err := sarama.ParseKafkaVersion(k.Version)
+	if err != nil {
+		return nil, fmt.Errorf("kafka.version: %w", err)
+	}
+	config.Version = version
+
+	switch k.Producer.RequiredAcks {
+	case "all":
+		config.Producer.RequiredAcks = sarama.WaitForAll
+	case "local":
+		config.Producer.RequiredAcks = sarama.WaitForLocal
+	case "none":
+		config.Producer.RequiredAcks = sarama.NoResponse
+	default:
+		return nil, fmt.Errorf("kafka.producer.required_acks: unknown value %q", k.Producer.RequiredAcks)
+	}
+
+	switch k.Producer.Partitioner {
+	case "hash":
+		config.Producer.Partitioner = sarama.NewHashPartitioner
+	case "roundrobin":
+		config.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	case "random":
+		config.Producer.Partitioner = sarama.NewRandomPartitioner
+	default:
+		return nil, fmt.Errorf("kafka.producer.partitioner: unknown value %q", k.Producer.Partitioner)
+	}
+
+	if err := config.Producer.Compression.UnmarshalText([]byte(k.Producer.Compression)); err != nil {
+		return nil, fmt.Errorf("kafka.producer.compression: %w", err)
+	}
+
+	config.Producer.Timeout = k.Producer.Timeout
+	config.Producer.MaxMessageBytes = k.Producer.MaxMessageBytes
+	config.Producer.Retry.Max = k.Producer.RetryMax
+	config.Producer.Retry.Backoff = k.Producer.RetryBackoff
+	if k.Producer.Idempotent {
+		config.Producer.Idempotent = true
+		config.Net.MaxOpenRequests = 1
+	}
+
+	switch k.Consumer.InitialOffset {
+	case "oldest":
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	case "newest":
+		config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	default:
+		return nil, fmt.Errorf("kafka.consumer.initial_offset: unknown value %q", k.Consumer.InitialOffset)
+	}
+
+	config.Consumer.Return.Errors = true
+	config.Consumer.Fetch.Min = k.Consumer.FetchMin
+	config.Consumer.Fetch.Default = k.Consumer.FetchDefault
+	config.Consumer.Fetch.Max = k.Consumer.FetchMax
+	config.Consumer.MaxWaitTime = k.Consumer.MaxWaitTime
+	config.Consumer.MaxProcessingTime = k.Consumer.MaxProcessingTime
+
+	return config, nil
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/xdg-go/scram"
+)
+
+func testKafka(broker *sarama.MockBroker) Kafka {
+	k := defaultKafka("test")
+	k.Brokers = []string{broker.Addr()}
+	return k
+}
+
+func connect(t *testing.T, k Kafka) error {
+	t.Helper()
+	config, err := k.Sarama()
+	if err != nil {
+		t.Fatalf("Sarama error: %v", err)
+	}
+	config.Metadata.Retry.Max = 0
+
+	client, err := sarama.NewClient(k.Brokers, config)
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
+func saslAuthBytes(broker *sarama.MockBroker) string {
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.SaslAuthenticateRequest); ok {
+			return string(req.SaslAuthBytes)
+		}
+	}
+	return ""
+}
+
+func saslBroker(t *testing.T, mechanism string) *sarama.MockBroker {
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":         sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID()),
+		"SaslHandshakeRequest":    sarama.NewMockSaslHandshakeResponse(t).SetEnabledMechanisms([]string{mechanism}),
+		"SaslAuthenticateRequest": sarama.NewMockSaslAuthenticateResponse(t),
+	})
+	return broker
+}
+
+func TestKafkaSASLPlain(t *testing.T) {
+	broker := saslBroker(t, sarama.SASLTypePlaintext)
+
+	k := testKafka(broker)
+	k.SASL = KafkaSASL{Mechanism: sarama.SASLTypePlaintext, Username: "user", Password: "pass"}
+	if err := connect(t, k); err != nil {
+		t.Fatalf("connect error: %v", err)
+	}
+
+	if got := saslAuthBytes(broker); got != "\x00user\x00pass" {
+		t.Errorf("Expected PLAIN credentials, got %q", got)
+	}
+}
+
+func TestKafkaSASLOAuth(t *testing.T) {
+	requests := 0
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "secret" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "kafka" {
+			http.Error(w, "bad client", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"from-url","expires_in":3600}`))
+	}))
+	defer tokens.Close()
+
+	tests := []struct {
+		name     string
+		sasl     KafkaSASL
+		provider sarama.AccessTokenProvider
+		token    string
+	}{
+		{"static", KafkaSASL{Token: "static"}, nil, "static"},
+		{"token url", KafkaSASL{TokenURL: tokens.URL, Username: "client", Password: "secret", Scopes: []string{"kafka"}}, nil, "from-url"},
+		{"provider", KafkaSASL{Token: "static"}, staticToken("from-code"), "from-code"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := saslBroker(t, sarama.SASLTypeOAuth)
+
+			k := testKafka(broker)
+			k.SASL = tt.sasl
+			k.SASL.Mechanism = sarama.SASLTypeOAuth
+			k.TokenProvider = tt.provider
+			if err := connect(t, k); err != nil {
+				t.Fatalf("connect error: %v", err)
+			}
+
+			if got := saslAuthBytes(broker); !strings.Contains(got, "auth=Bearer "+tt.token) {
+				t.Errorf("Expected bearer token %s, got %q", tt.token, got)
+			}
+		})
+	}
+
+	if requests != 1 {
+		t.Errorf("Expected one token request, got %d", requests)
+	}
+}
+
+func TestKafkaSASLValidates(t *testing.T) {
+	tests := []KafkaSASL{
+		{Mechanism: "GSSAPI"},
+		{Mechanism: sarama.SASLTypeSCRAMSHA256, Username: "user"},
+		{Mechanism: sarama.SASLTypeOAuth},
+	}
+	for _, sasl := range tests {
+		k := defaultKafka("test")
+		k.SASL = sasl
+		if err := k.Validate(); err == nil {
+			t.Errorf("Expected %s to fail validation", sasl.Mechanism)
+		}
+	}
+}
+
+func TestScramClient(t *testing.T) {
+	for _, hash := range []scram.HashGeneratorFcn{scram.SHA256, scram.SHA512} {
+		reference, err := hash.NewClient("user", "pass", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		credentials := reference.GetStoredCredentials(scram.KeyFactors{Salt: "salt", Iters: 4096})
+		server, err := hash.NewServer(func(string) (scram.StoredCredentials, error) { return credentials, nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		conversation := server.NewConversation()
+
+		client := &scramClient{hash: hash}
+		if err := client.Begin("user", "pass", ""); err != nil {
+			t.Fatal(err)
+		}
+
+		challenge := ""
+		for !client.Done() {
+			response, err := client.Step(challenge)
+			if err != nil {
+				t.Fatalf("client step error: %v", err)
+			}
+			if client.Done() {
+				break
+			}
+			challenge, err = conversation.Step(response)
+			if err != nil {
+				t.Fatalf("server step error: %v", err)
+			}
+		}
+
+		if !conversation.Valid() {
+			t.Error("Expected the server to accept the SCRAM exchange")
+		}
+	}
+}
+
+func TestKafkaTLS(t *testing.T) {
+	dir := t.TempDir()
+	cert := writeCert(t, dir)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := sarama.NewMockBrokerListener(t, 1, listener)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).SetBroker(broker.Addr(), broker.BrokerID()),
+	})
+
+	k := testKafka(broker)
+	k.TLS = KafkaTLS{Enabled: true, CAFile: filepath.Join(dir, "ca.pem")}
+	if err := connect(t, k); err != nil {
+		t.Fatalf("connect with CA error: %v", err)
+	}
+
+	k.TLS = KafkaTLS{Enabled: true}
+	if err := connect(t, k); err == nil {
+		t.Error("Expected an unknown certificate authority to be rejected")
+	}
+}
+
+// writeCert creates a self-signed certificate for 127.0.0.1 and writes it
+// to dir/ca.pem.
+func writeCert(t *testing.T, dir string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "broker"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, "ca.pem"), certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
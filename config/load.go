@@ -0,0 +1,165 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// load fills dst, a pointer to a struct holding defaults, from an optional
+// YAML file, then environment variables, then command line flags. Later
+// sources win. It returns the arguments left after the flags.
+//
+// Fields opt in with struct tags:
+//
+//	yaml:"brokers" env:"KAFKA_BROKERS,BROKER" flag:"kafka-brokers" usage:"..."
+//
+// The env tag may list several names; the first one set is used. Supported
+// field types are string, bool, ints, time.Duration and []string (comma
+// separated in env and flags).
+func load(name string, dst any, args []string) ([]string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "optional YAML config file (env CONFIG_FILE)")
+
+	// The file has to be read before env and flags are applied, so look for
+	// -config ahead of the real parse.
+	path := *file
+	for i, arg := range args {
+		arg = strings.TrimLeft(arg, "-")
+		if arg == "config" && i+1 < len(args) {
+			path = args[i+1]
+		} else if strings.HasPrefix(arg, "config=") {
+			path = strings.TrimPrefix(arg, "config=")
+		}
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		if err := yaml.Unmarshal(data, dst); err != nil {
+			return nil, fmt.Errorf("config: %s: %w", path, err)
+		}
+	}
+
+	var errs []error
+	walk(reflect.ValueOf(dst).Elem(), func(f reflect.StructField, v reflect.Value) {
+		for _, env := range strings.Split(f.Tag.Get("env"), ",") {
+			if env == "" {
+				continue
+			}
+			if s, ok := os.LookupEnv(env); ok {
+				if err := set(v, s); err != nil {
+					errs = append(errs, fmt.Errorf("config: %s: %w", env, err))
+				}
+				break
+			}
+		}
+
+		if name := f.Tag.Get("flag"); name != "" {
+			usage := f.Tag.Get("usage")
+			if env := f.Tag.Get("env"); env != "" {
+				usage += " (env " + strings.ReplaceAll(env, ",", ", ") + ")"
+			}
+			fs.Var(fieldValue{v}, name, usage)
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return fs.Args(), nil
+}
+
+// walk calls fn for every tagged leaf field of the struct v, descending into
+// nested structs.
+func walk(v reflect.Value, fn func(reflect.StructField, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Duration(0)) {
+			walk(fv, fn)
+			continue
+		}
+		fn(f, fv)
+	}
+}
+
+func set(v reflect.Value, s string) error {
+	switch v.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case []string:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
+
+type fieldValue struct {
+	v reflect.Value
+}
+
+func (f fieldValue) String() string {
+	if !f.v.IsValid() {
+		return ""
+	}
+	if list, ok := f.v.Interface().([]string); ok {
+		return strings.Join(list, ",")
+	}
+	return fmt.Sprint(f.v.Interface())
+}
+
+func (f fieldValue) Set(s string) error {
+	return set(f.v, s)
+}
+
+func (f fieldValue) IsBoolFlag() bool {
+	return f.v.IsValid() && f.v.Kind() == reflect.Bool
+}
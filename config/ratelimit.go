@@ -0,0 +1,48 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RateLimit gives each client of the skill API a token bucket for reads and
+// another for writes. A client is an API key or token subject, or the address
+// of an anonymous caller. Each bucket holds its budget of requests and refills
+// over Period, so bursts up to the budget are allowed. Failures limits
+// requests refused authentication by address, so credentials cannot be
+// guessed at full speed.
+type RateLimit struct {
+	// Store keeps the buckets in memory, per replica, or in the database,
+	// shared by every replica of the API.
+	Store    string        `yaml:"store" env:"RATE_LIMIT_STORE" flag:"rate-limit-store" usage:"where buckets are kept: memory or database"`
+	Read     int           `yaml:"read" env:"RATE_LIMIT_READ" flag:"rate-limit-read" usage:"GET requests each client may make per period, 0 for no limit"`
+	Write    int           `yaml:"write" env:"RATE_LIMIT_WRITE" flag:"rate-limit-write" usage:"other requests each client may make per period, 0 for no limit"`
+	Failures int           `yaml:"failures" env:"RATE_LIMIT_FAILURES" flag:"rate-limit-failures" usage:"failed authentications each address may make per period, 0 for no limit"`
+	Period   time.Duration `yaml:"period" env:"RATE_LIMIT_PERIOD" flag:"rate-limit-period" usage:"time for an emptied bucket to refill"`
+}
+
+// Validate checks r against the database it may keep its buckets in.
+func (r RateLimit) Validate(db Database) error {
+	var errs []error
+	switch r.Store {
+	case "memory":
+	case "database":
+		if db.Driver == "memory" {
+			errs = append(errs, errors.New("rate_limit.store database needs a postgres or sqlite database"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.store must be memory or database, got %q", r.Store))
+	}
+	if r.Read < 0 || r.Write < 0 || r.Failures < 0 {
+		errs = append(errs, errors.New("rate_limit.read, rate_limit.write and rate_limit.failures must not be negative"))
+	}
+	if (r.Read > 0 || r.Write > 0 || r.Failures > 0) && r.Period <= 0 {
+		errs = append(errs, errors.New("rate_limit.period must be positive when a limit is set"))
+	}
+	return errors.Join(errs...)
+}
+
+func defaultRateLimit() RateLimit {
+	return RateLimit{Store: "memory", Read: 600, Write: 60, Failures: 10, Period: time.Minute}
+}
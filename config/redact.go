@@ -0,0 +1,101 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const mask = "******"
+
+// Redacted renders cfg as YAML with every field tagged secret:"true" masked.
+// Fields tagged secret:"url" keep everything but the passwords of a
+// scheme:// URL, in its userinfo or password and sslpassword parameters.
+// Anything else, such as a key/value DSN, is masked whole.
+func Redacted(cfg any) string {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	out, err := yaml.Marshal(redact(v))
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+func redact(v reflect.Value) map[string]any {
+	m := map[string]any{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		fv := v.Field(i)
+		switch value := fv.Interface().(type) {
+		case time.Duration:
+			m[name] = value.String()
+			continue
+		case string:
+			m[name] = redactString(f.Tag.Get("secret"), value)
+			continue
+		case []string:
+			list := make([]string, len(value))
+			for i, s := range value {
+				list[i] = redactString(f.Tag.Get("secret"), s)
+			}
+			m[name] = list
+			continue
+		}
+
+		if fv.Kind() == reflect.Struct {
+			m[name] = redact(fv)
+			continue
+		}
+		m[name] = fv.Interface()
+	}
+	return m
+}
+
+func redactString(secret, s string) string {
+	if s == "" {
+		return s
+	}
+
+	switch secret {
+	case "true":
+		return mask
+	case "url":
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme == "" || !strings.HasPrefix(s[len(u.Scheme):], "://") {
+			return mask
+		}
+		if u.RawQuery != "" {
+			q := u.Query()
+			for key := range q {
+				if passwordKeys[strings.ToLower(key)] {
+					q.Set(key, mask)
+				}
+			}
+			u.RawQuery = q.Encode()
+		}
+		return u.Redacted()
+	}
+	return s
+}
+
+// passwordKeys are the connection parameters holding a password.
+var passwordKeys = map[string]bool{"password": true, "sslpassword": true}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/xdg-go/scram"
+)
+
+type KafkaSASL struct {
+	Mechanism string `yaml:"mechanism" env:"KAFKA_SASL_MECHANISM" flag:"kafka-sasl-mechanism" usage:"PLAIN, SCRAM-SHA-256, SCRAM-SHA-512 or OAUTHBEARER, empty to disable"`
+	Username  string `yaml:"username" env:"KAFKA_SASL_USERNAME" flag:"kafka-sasl-username" usage:"SASL user, or OAuth client ID with token_url"`
+	Password  string `yaml:"password" env:"KAFKA_SASL_PASSWORD" flag:"kafka-sasl-password" usage:"SASL password, or OAuth client secret with token_url" secret:"true"`
+
+	// OAUTHBEARER tokens come from Kafka.TokenProvider when set in code, then
+	// a static Token, then an OAuth client credentials grant against TokenURL.
+	Token    string   `yaml:"token" env:"KAFKA_SASL_TOKEN" flag:"kafka-sasl-token" usage:"static OAUTHBEARER token" secret:"true"`
+	TokenURL string   `yaml:"token_url" env:"KAFKA_SASL_TOKEN_URL" flag:"kafka-sasl-token-url" usage:"OAuth token endpoint for OAUTHBEARER"`
+	Scopes   []string `yaml:"scopes" env:"KAFKA_SASL_SCOPES" flag:"kafka-sasl-scopes" usage:"comma separated OAuth scopes"`
+}
+
+func (s KafkaSASL) apply(config *sarama.Config, provider sarama.AccessTokenProvider) error {
+	if s.Mechanism == "" {
+		return nil
+	}
+
+	config.Net.SASL.Enable = true
+	config.Net.SASL.Handshake = true
+	config.Net.SASL.User = s.Username
+	config.Net.SASL.Password = s.Password
+
+	switch s.Mechanism {
+	case sarama.SASLTypePlaintext:
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case sarama.SASLTypeSCRAMSHA256:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hash: scram.SHA256} }
+	case sarama.SASLTypeSCRAMSHA512:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hash: scram.SHA512} }
+	case sarama.SASLTypeOAuth:
+		config.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+		switch {
+		case provider != nil:
+		case s.Token != "":
+			provider = staticToken(s.Token)
+		case s.TokenURL != "":
+			provider = &clientCredentials{url: s.TokenURL, id: s.Username, secret: s.Password, scopes: s.Scopes}
+		default:
+			return errors.New("kafka.sasl: OAUTHBEARER needs a token, a token_url or a token provider")
+		}
+		config.Net.SASL.TokenProvider = provider
+		return nil
+	default:
+		return fmt.Errorf("kafka.sasl.mechanism: unknown value %q", s.Mechanism)
+	}
+
+	if s.Username == "" || s.Password == "" {
+		return fmt.Errorf("kafka.sasl: %s needs a username and password", s.Mechanism)
+	}
+	return nil
+}
+
+// scramClient adapts xdg-go/scram to sarama's SCRAMClient.
+type scramClient struct {
+	hash         scram.HashGeneratorFcn
+	conversation *scram.ClientConversation
+}
+
+func (c *scramClient) Begin(user, password, authzID string) error {
+	client, err := c.hash.NewClient(user, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
+
+type staticToken string
+
+func (t staticToken) Token() (*sarama.AccessToken, error) {
+	return &sarama.AccessToken{Token: string(t)}, nil
+}
+
+// clientCredentials fetches OAUTHBEARER tokens with the OAuth 2.0 client
+// credentials grant and caches them until shortly before they expire.
+type clientCredentials struct {
+	url    string
+	id     string
+	secret string
+	scopes []string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (c *clientCredentials) Token() (*sarama.AccessToken, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expires) {
+		return &sarama.AccessToken{Token: c.token}, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.id), url.QueryEscape(c.secret))
+
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kafka.sasl: fetch token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kafka.sasl: fetch token: %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("kafka.sasl: fetch token: %w", err)
+	}
+
+	c.token = body.AccessToken
+	// Refresh a little early so a token never expires mid-handshake.
+	c.expires = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - 30*time.Second)
+
+	return &sarama.AccessToken{Token: c.token}, nil
+}
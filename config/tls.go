@@ -0,0 +1,50 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+type KafkaTLS struct {
+	Enabled            bool   `yaml:"enabled" env:"KAFKA_TLS_ENABLED" flag:"kafka-tls" usage:"connect to the brokers over TLS"`
+	CAFile             string `yaml:"ca_file" env:"KAFKA_TLS_CA_FILE" flag:"kafka-tls-ca-file" usage:"PEM bundle used to verify the brokers, system roots when empty"`
+	CertFile           string `yaml:"cert_file" env:"KAFKA_TLS_CERT_FILE" flag:"kafka-tls-cert-file" usage:"PEM client certificate for mutual TLS"`
+	KeyFile            string `yaml:"key_file" env:"KAFKA_TLS_KEY_FILE" flag:"kafka-tls-key-file" usage:"PEM client key for mutual TLS"`
+	ServerName         string `yaml:"server_name" env:"KAFKA_TLS_SERVER_NAME" flag:"kafka-tls-server-name" usage:"name expected in the broker certificates, the broker host when empty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" env:"KAFKA_TLS_INSECURE_SKIP_VERIFY" flag:"kafka-tls-insecure-skip-verify" usage:"skip broker certificate verification, for development only"`
+}
+
+func (t KafkaTLS) config() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka.tls.ca_file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("kafka.tls.ca_file: no certificates found in %s", t.CAFile)
+		}
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return nil, errors.New("kafka.tls.cert_file and kafka.tls.key_file must be set together")
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka.tls: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
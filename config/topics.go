@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// KafkaTopics describes the topics the services expect. The command topic is
+// Kafka.Topic; DeadLetter is meant for commands that could not be applied and
+// Result for their outcomes. An empty name leaves that topic alone.
+type KafkaTopics struct {
+	Ensure            bool          `yaml:"ensure" env:"KAFKA_TOPICS_ENSURE" flag:"kafka-topics-ensure" usage:"create missing topics and check existing ones on startup"`
+	DeadLetter        string        `yaml:"dead_letter" env:"KAFKA_TOPICS_DEAD_LETTER" flag:"kafka-topics-dead-letter" usage:"topic for commands that could not be applied"`
+	Result            string        `yaml:"result" env:"KAFKA_TOPICS_RESULT" flag:"kafka-topics-result" usage:"topic for command results"`
+	Partitions        int32         `yaml:"partitions" env:"KAFKA_TOPICS_PARTITIONS" flag:"kafka-topics-partitions" usage:"partitions per topic"`
+	ReplicationFactor int16         `yaml:"replication_factor" env:"KAFKA_TOPICS_REPLICATION_FACTOR" flag:"kafka-topics-replication-factor" usage:"replicas per partition"`
+	Retention         time.Duration `yaml:"retention" env:"KAFKA_TOPICS_RETENTION" flag:"kafka-topics-retention" usage:"retention.ms of the topics, 0 leaves the broker default"`
+	CleanupPolicy     string        `yaml:"cleanup_policy" env:"KAFKA_TOPICS_CLEANUP_POLICY" flag:"kafka-topics-cleanup-policy" usage:"delete, compact or compact,delete, empty leaves the broker default"`
+}
+
+func (t KafkaTopics) validate() error {
+	var errs []error
+	if t.Partitions < 1 {
+		errs = append(errs, errors.New("kafka.topics.partitions must be at least 1"))
+	}
+	if t.ReplicationFactor < 1 {
+		errs = append(errs, errors.New("kafka.topics.replication_factor must be at least 1"))
+	}
+	if t.Retention < 0 {
+		errs = append(errs, errors.New("kafka.topics.retention must not be negative"))
+	}
+	switch t.CleanupPolicy {
+	case "", "delete", "compact", "compact,delete", "delete,compact":
+	default:
+		errs = append(errs, fmt.Errorf("kafka.topics.cleanup_policy: unknown value %q", t.CleanupPolicy))
+	}
+	return errors.Join(errs...)
+}
+
+// TopicDetails returns the expected settings of every topic keyed by name.
+func (k Kafka) TopicDetails() map[string]sarama.TopicDetail {
+	entries := map[string]*string{}
+	if k.Topics.Retention > 0 {
+		retention := strconv.FormatInt(k.Topics.Retention.Milliseconds(), 10)
+		entries["retention.ms"] = &retention
+	}
+	if k.Topics.CleanupPolicy != "" {
+		policy := k.Topics.CleanupPolicy
+		entries["cleanup.policy"] = &policy
+	}
+
+	details := map[string]sarama.TopicDetail{}
+	for _, name := range []string{k.Topic, k.Topics.DeadLetter, k.Topics.Result} {
+		if name == "" {
+			continue
+		}
+		details[name] = sarama.TopicDetail{
+			NumPartitions:     k.Topics.Partitions,
+			ReplicationFactor: k.Topics.ReplicationFactor,
+			ConfigEntries:     entries,
+		}
+	}
+	return details
+}
+
+// EnsureTopics creates the missing topics and fails if an existing one has a
+// different partition count, replication factor or config.
+func (k Kafka) EnsureTopics() error {
+	config, err := k.Sarama()
+	if err != nil {
+		return err
+	}
+
+	admin, err := sarama.NewClusterAdmin(k.Brokers, config)
+	if err != nil {
+		return fmt.Errorf("kafka: connect admin: %w", err)
+	}
+	defer admin.Close()
+
+	return ensureTopics(admin, k.TopicDetails())
+}
+
+// topicAdmin is the part of sarama.ClusterAdmin used by ensureTopics.
+type topicAdmin interface {
+	ListTopics() (map[string]sarama.TopicDetail, error)
+	CreateTopic(topic string, detail *sarama.TopicDetail, validateOnly bool) error
+	DescribeConfig(resource sarama.ConfigResource) ([]sarama.ConfigEntry, error)
+}
+
+func ensureTopics(admin topicAdmin, want map[string]sarama.TopicDetail) error {
+	existing, err := admin.ListTopics()
+	if err != nil {
+		return fmt.Errorf("kafka: list topics: %w", err)
+	}
+
+	var errs []error
+	for name, detail := range want {
+		have, ok := existing[name]
+		if !ok {
+			if err := admin.CreateTopic(name, &detail, false); err != nil && !errors.Is(err, sarama.ErrTopicAlreadyExists) {
+				errs = append(errs, fmt.Errorf("kafka: create topic %s: %w", name, err))
+			}
+			continue
+		}
+
+		if have.NumPartitions != detail.NumPartitions {
+			errs = append(errs, fmt.Errorf("kafka: topic %s has %d partitions, expected %d", name, have.NumPartitions, detail.NumPartitions))
+		}
+		if have.ReplicationFactor != detail.ReplicationFactor {
+			errs = append(errs, fmt.Errorf("kafka: topic %s has replication factor %d, expected %d", name, have.ReplicationFactor, detail.ReplicationFactor))
+		}
+		if len(detail.ConfigEntries) == 0 {
+			continue
+		}
+
+		entries, err := admin.DescribeConfig(sarama.ConfigResource{Type: sarama.TopicResource, Name: name})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("kafka: describe topic %s: %w", name, err))
+			continue
+		}
+		values := map[string]string{}
+		for _, entry := range entries {
+			values[entry.Name] = entry.Value
+		}
+		for key, value := range detail.ConfigEntries {
+			if !sameConfig(key, values[key], *value) {
+				errs = append(errs, fmt.Errorf("kafka: topic %s has %s=%q, expected %q", name, key, values[key], *value))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func sameConfig(key, have, want string) bool {
+	if key != "cleanup.policy" {
+		return have == want
+	}
+	// The broker may list compact,delete in either order.
+	split := func(s string) map[string]bool {
+		set := map[string]bool{}
+		for _, part := range strings.Split(s, ",") {
+			set[strings.TrimSpace(part)] = true
+		}
+		return set
+	}
+	a, b := split(have), split(want)
+	if len(a) != len(b) {
+		return false
+	}
+	for part := range a {
+		if !b[part] {
+			return false
+		}
+	}
+	return true
+}
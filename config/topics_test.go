@@ -0,0 +1,129 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+type fakeAdmin struct {
+	topics  map[string]sarama.TopicDetail
+	configs map[string][]sarama.ConfigEntry
+	created map[string]sarama.TopicDetail
+}
+
+func (a *fakeAdmin) ListTopics() (map[string]sarama.TopicDetail, error) {
+	return a.topics, nil
+}
+
+func (a *fakeAdmin) CreateTopic(topic string, detail *sarama.TopicDetail, validateOnly bool) error {
+	a.created[topic] = *detail
+	return nil
+}
+
+func (a *fakeAdmin) DescribeConfig(resource sarama.ConfigResource) ([]sarama.ConfigEntry, error) {
+	return a.configs[resource.Name], nil
+}
+
+func TestEnsureTopics(t *testing.T) {
+	k := defaultKafka("test")
+	k.Topics.CleanupPolicy = "compact,delete"
+	want := k.TopicDetails()
+
+	t.Run("CreatesMissing", func(t *testing.T) {
+		admin := &fakeAdmin{
+			topics: map[string]sarama.TopicDetail{
+				"skill": {NumPartitions: 1, ReplicationFactor: 1},
+			},
+			configs: map[string][]sarama.ConfigEntry{
+				"skill": {{Name: "retention.ms", Value: "604800000"}, {Name: "cleanup.policy", Value: "delete,compact"}},
+			},
+			created: map[string]sarama.TopicDetail{},
+		}
+
+		if err := ensureTopics(admin, want); err != nil {
+			t.Fatalf("ensureTopics error: %v", err)
+		}
+
+		if len(admin.created) != 2 {
+			t.Fatalf("Expected 2 topics created, got %v", admin.created)
+		}
+		for _, name := range []string{"skill.dlq", "skill.result"} {
+			detail, ok := admin.created[name]
+			if !ok {
+				t.Errorf("Expected %s to be created", name)
+				continue
+			}
+			if *detail.ConfigEntries["retention.ms"] != "604800000" {
+				t.Errorf("Expected a week of retention, got %s", *detail.ConfigEntries["retention.ms"])
+			}
+		}
+	})
+
+	t.Run("RejectsMismatch", func(t *testing.T) {
+		admin := &fakeAdmin{
+			topics: map[string]sarama.TopicDetail{
+				"skill":        {NumPartitions: 3, ReplicationFactor: 1},
+				"skill.dlq":    {NumPartitions: 1, ReplicationFactor: 1},
+				"skill.result": {NumPartitions: 1, ReplicationFactor: 1},
+			},
+			configs: map[string][]sarama.ConfigEntry{
+				"skill":        {{Name: "retention.ms", Value: "604800000"}, {Name: "cleanup.policy", Value: "compact,delete"}},
+				"skill.dlq":    {{Name: "retention.ms", Value: "1000"}, {Name: "cleanup.policy", Value: "compact,delete"}},
+				"skill.result": {{Name: "retention.ms", Value: "604800000"}, {Name: "cleanup.policy", Value: "delete"}},
+			},
+			created: map[string]sarama.TopicDetail{},
+		}
+
+		err := ensureTopics(admin, want)
+		if err == nil {
+			t.Fatal("Expected a mismatch error")
+		}
+		for _, msg := range []string{
+			"topic skill has 3 partitions, expected 1",
+			`topic skill.dlq has retention.ms="1000"`,
+			`topic skill.result has cleanup.policy="delete"`,
+		} {
+			if !strings.Contains(err.Error(), msg) {
+				t.Errorf("Expected error to mention %q, got %v", msg, err)
+			}
+		}
+		if len(admin.created) != 0 {
+			t.Errorf("Expected nothing created, got %v", admin.created)
+		}
+	})
+}
+
+func TestEnsureTopicsBroker(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetController(broker.BrokerID()).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+		"CreateTopicsRequest":    sarama.NewMockCreateTopicsResponse(t),
+		"DescribeConfigsRequest": sarama.NewMockDescribeConfigsResponse(t),
+	})
+
+	k := testKafka(broker)
+	k.Topics.Retention = 0
+	k.Topics.CleanupPolicy = ""
+	if err := k.EnsureTopics(); err != nil {
+		t.Fatalf("EnsureTopics error: %v", err)
+	}
+
+	created := map[string]bool{}
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.CreateTopicsRequest); ok {
+			for name := range req.TopicDetails {
+				created[name] = true
+			}
+		}
+	}
+	for _, name := range []string{"skill", "skill.dlq", "skill.result"} {
+		if !created[name] {
+			t.Errorf("Expected a CreateTopics request for %s", name)
+		}
+	}
+}
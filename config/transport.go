@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Transport selects the message broker carrying skill commands. The Kafka
+// backend is configured by the top-level Kafka section.
+type Transport struct {
+	Backend      string        `yaml:"backend" env:"TRANSPORT_BACKEND" flag:"transport" usage:"kafka, nats or redis"`
+	RetryBackoff time.Duration `yaml:"retry_backoff" env:"TRANSPORT_RETRY_BACKOFF" flag:"transport-retry-backoff" usage:"wait before redelivering a message whose handler failed"`
+	NATS         NATS          `yaml:"nats"`
+	Redis        Redis         `yaml:"redis"`
+}
+
+type NATS struct {
+	URL     string        `yaml:"url" env:"NATS_URL" flag:"nats-url" usage:"NATS server URL" secret:"url"`
+	Stream  string        `yaml:"stream" env:"NATS_STREAM" flag:"nats-stream" usage:"JetStream stream, created when missing"`
+	Subject string        `yaml:"subject" env:"NATS_SUBJECT" flag:"nats-subject" usage:"subject carrying skill commands"`
+	Durable string        `yaml:"durable" env:"NATS_DURABLE" flag:"nats-durable" usage:"durable consumer name shared by consumer instances"`
+	AckWait time.Duration `yaml:"ack_wait" env:"NATS_ACK_WAIT" flag:"nats-ack-wait" usage:"time before an unacknowledged message is redelivered"`
+}
+
+type Redis struct {
+	Addr     string `yaml:"addr" env:"REDIS_ADDR" flag:"redis-addr" usage:"Redis host:port"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD" flag:"redis-password" usage:"Redis password" secret:"true"`
+	DB       int    `yaml:"db" env:"REDIS_DB" flag:"redis-db" usage:"Redis database number"`
+	Stream   string `yaml:"stream" env:"REDIS_STREAM" flag:"redis-stream" usage:"stream carrying skill commands"`
+	Group    string `yaml:"group" env:"REDIS_GROUP" flag:"redis-group" usage:"consumer group shared by consumer instances"`
+	Consumer string `yaml:"consumer" env:"REDIS_CONSUMER" flag:"redis-consumer" usage:"name of this instance within the group, the hostname when empty"`
+	MaxLen   int64  `yaml:"max_len" env:"REDIS_MAX_LEN" flag:"redis-max-len" usage:"approximate stream length cap, 0 for no cap"`
+	// Lease is how long the instance reading the stream may go without
+	// renewing its claim before another instance of the group takes over.
+	Lease time.Duration `yaml:"lease" env:"REDIS_LEASE" flag:"redis-lease" usage:"time after which a silent reading instance is replaced by another"`
+}
+
+func defaultTransport() Transport {
+	return Transport{
+		Backend:      "kafka",
+		RetryBackoff: time.Second,
+		NATS: NATS{
+			URL:     "nats://localhost:4222",
+			Stream:  "SKILL",
+			Subject: "skill.commands",
+			Durable: "skill-consumer",
+			AckWait: 30 * time.Second,
+		},
+		Redis: Redis{
+			Addr:   "localhost:6379",
+			Stream: "skill",
+			Group:  "skill-consumer",
+			Lease:  10 * time.Second,
+		},
+	}
+}
+
+// Validate checks the settings of the selected backend.
+func (t Transport) Validate(kafka Kafka) error {
+	if t.RetryBackoff < 0 {
+		return errors.New("transport.retry_backoff must not be negative")
+	}
+
+	switch t.Backend {
+	case "kafka":
+		return kafka.Validate()
+	case "nats":
+		var errs []error
+		if t.NATS.URL == "" {
+			errs = append(errs, errors.New("transport.nats.url is required"))
+		}
+		if t.NATS.Stream == "" || t.NATS.Subject == "" || t.NATS.Durable == "" {
+			errs = append(errs, errors.New("transport.nats.stream, subject and durable are required"))
+		}
+		return errors.Join(errs...)
+	case "redis":
+		var errs []error
+		if t.Redis.Addr == "" {
+			errs = append(errs, errors.New("transport.redis.addr is required"))
+		}
+		if t.Redis.Stream == "" || t.Redis.Group == "" {
+			errs = append(errs, errors.New("transport.redis.stream and group are required"))
+		}
+		if t.Redis.Lease <= 0 {
+			errs = append(errs, errors.New("transport.redis.lease must be positive"))
+		}
+		return errors.Join(errs...)
+	default:
+		return fmt.Errorf("transport.backend: unknown value %q", t.Backend)
+	}
+}
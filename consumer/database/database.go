@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/narunart-atise/skill-api-kafka/logging"
+)
+
+// maxConnectBackoff caps the wait between connect attempts.
+const maxConnectBackoff = 10 * time.Second
+
+// Open connects to the configured Postgres or SQLite database and waits until
+// it answers, so a wrong URL fails at startup instead of on the first query.
+// The memory driver has no *sql.DB; callers build their storage on memdb
+// instead.
+func Open(ctx context.Context, cfg config.Database) (*sql.DB, func()) {
+	var (
+		db    *sql.DB
+		close func()
+	)
+	switch cfg.Driver {
+	case "postgres":
+		db, close = NewPostgres(cfg.URL)
+		configurePool(db, cfg.Pool)
+	case "sqlite":
+		db, close = NewSQLite(cfg.URL)
+	default:
+		logging.Fatal("Unsupported database driver", "driver", cfg.Driver)
+	}
+
+	if err := Connect(ctx, db, cfg.ConnectAttempts, cfg.ConnectBackoff, cfg.ConnectTimeout); err != nil {
+		close()
+		logging.Fatal("Database is not reachable", "error", err)
+	}
+
+	if expvar.Get("database") == nil {
+		expvar.Publish("database", expvar.Func(func() any { return db.Stats() }))
+	}
+
+	return db, close
+}
+
+func configurePool(db *sql.DB, pool config.DatabasePool) {
+	db.SetMaxOpenConns(pool.MaxOpen)
+	db.SetMaxIdleConns(pool.MaxIdle)
+	db.SetConnMaxLifetime(pool.MaxLifetime)
+	db.SetConnMaxIdleTime(pool.MaxIdleTime)
+}
+
+// Connect pings db until it answers, up to attempts times. The wait between
+// attempts starts at backoff and doubles each time, up to maxConnectBackoff.
+func Connect(ctx context.Context, db *sql.DB, attempts int, backoff, timeout time.Duration) error {
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("gave up after %d attempts: %w", attempt, err)
+		}
+		slog.WarnContext(ctx, "Database not ready, retrying", "attempt", attempt, "attempts", attempts, "backoff", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxConnectBackoff)
+	}
+}
+
+// LogStats logs the connection pool statistics every interval until ctx is
+// cancelled. The same numbers are published as the "database" expvar.
+func LogStats(ctx context.Context, db *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		s := db.Stats()
+		slog.InfoContext(ctx, "Database pool",
+			"open", s.OpenConnections, "max_open", s.MaxOpenConnections, "in_use", s.InUse, "idle", s.Idle,
+			"waits", s.WaitCount, "wait_duration", s.WaitDuration,
+			"closed_idle", s.MaxIdleClosed+s.MaxIdleTimeClosed, "closed_lifetime", s.MaxLifetimeClosed)
+	}
+}
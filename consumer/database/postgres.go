@@ -2,16 +2,16 @@ package database
 
 import (
 	"database/sql"
-	"log"
-	"os"
 
 	_ "github.com/lib/pq"
+	"github.com/narunart-atise/skill-api-kafka/logging"
+	"github.com/narunart-atise/skill-api-kafka/tracing"
 )
 
-func NewPostgres() (*sql.DB, func()) {
-	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+func NewPostgres(url string) (*sql.DB, func()) {
+	db, err := tracing.OpenDB("postgres", url)
 	if err != nil {
-		log.Fatal("Connect to database error", err)
+		logging.Fatal("Connect to database error", "error", err)
 	}
 
 	close := func() {
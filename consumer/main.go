@@ -1,27 +1,141 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
+	"github.com/narunart-atise/skill-api-kafka/config"
 	"github.com/narunart-atise/skill-api-kafka/consumer/database"
 	"github.com/narunart-atise/skill-api-kafka/consumer/skill"
+	"github.com/narunart-atise/skill-api-kafka/health"
+	"github.com/narunart-atise/skill-api-kafka/logging"
+	"github.com/narunart-atise/skill-api-kafka/metrics"
+	"github.com/narunart-atise/skill-api-kafka/migrations"
+	"github.com/narunart-atise/skill-api-kafka/tracing"
+	"github.com/narunart-atise/skill-api-kafka/transport"
 )
 
 func main() {
-	broker := os.Getenv("BROKER")
-	topic := os.Getenv("TOPIC")
+	cfg, args, err := config.LoadConsumer(os.Args[1:])
+	if err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	if err := logging.Setup(cfg.Log, "skill-consumer"); err != nil {
+		logging.Fatal("Failed to set up logging", "error", err)
+	}
+	slog.Info("Effective configuration", "config", config.Redacted(cfg))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, "skill-consumer")
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
 
-	db, closeDB := database.NewPostgres()
+	db, closeDB := database.Open(ctx, cfg.Database)
 	defer closeDB()
 
+	if len(args) > 0 && args[0] == "migrate" {
+		if cfg.Database.Driver != "postgres" {
+			logging.Fatal("Migrate needs a postgres database, other drivers create their schema on startup", "driver", cfg.Database.Driver)
+		}
+		if err := migrations.Run(ctx, db, args[1:], os.Stdout); err != nil {
+			logging.Fatal("Migrate failed", "error", err)
+		}
+		return
+	}
+
+	// SQLite creates its schema when it is opened.
+	if cfg.AutoMigrate && cfg.Database.Driver == "postgres" {
+		m, err := migrations.New(db)
+		if err != nil {
+			logging.Fatal("Failed to load migrations", "error", err)
+		}
+		if err := m.Up(ctx); err != nil {
+			logging.Fatal("Failed to migrate database", "error", err)
+		}
+	}
+
+	if cfg.Database.StatsInterval > 0 {
+		go database.LogStats(ctx, db, cfg.Database.StatsInterval)
+	}
+	metrics.RegisterDatabase(db, "primary")
+
 	storage := skill.NewStorage(db)
 
-	consumer, err := skill.NewConsumer(broker, topic, storage)
+	// Soft-deleted skills are kept forever unless a purge retention is set.
+	// A purge in progress is waited for before the database is closed.
+	var purging sync.WaitGroup
+	defer purging.Wait()
+	if cfg.Purge.Retention > 0 {
+		purging.Add(1)
+		go func() {
+			defer purging.Done()
+			skill.NewPurger(storage, cfg.Purge.Retention, cfg.Purge.Interval).Run(ctx)
+		}()
+	}
+
+	if cfg.Transport.Backend == "kafka" && cfg.Kafka.Topics.Ensure {
+		if err := cfg.Kafka.EnsureTopics(); err != nil {
+			logging.Fatal("Kafka topics are not as expected", "error", err)
+		}
+	}
+
+	subscriber, err := transport.NewSubscriber(cfg.Transport, cfg.Kafka)
 	if err != nil {
-		log.Fatalf("Failed to create Kafka consumer: %v", err)
+		logging.Fatal("Failed to create subscriber", "backend", cfg.Transport.Backend, "error", err)
 	}
+	metrics.RegisterSarama(subscriber, "consumer")
+	metrics.RegisterLag(subscriber, cfg.Health.Timeout)
+	consumer := skill.NewConsumer(subscriber, storage, cfg.DrainTimeout)
 	defer consumer.Close()
 
-	consumer.Consume()
+	checker := health.New(cfg.Health.Timeout)
+	checker.Add("database", health.Database(db))
+	checker.Add(cfg.Transport.Backend, health.Transport(subscriber, cfg.Health.MaxLag))
+
+	srv := serveHealth(cfg.HTTP, checker)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Error("Health server shutdown failed", "error", err)
+		}
+	}()
+
+	// Consume returns once the message in hand is handled, and only then
+	// are the subscriber and the database closed, by the deferred calls.
+	if err := consumer.Consume(ctx); err != nil {
+		slog.Error("Consumer failed", "error", err)
+	}
+}
+
+// serveHealth serves the liveness and readiness probes, the metrics and the
+// debug variables in the background.
+func serveHealth(cfg config.HTTP, checker *health.Checker) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", metrics.Instrument("/healthz", http.HandlerFunc(health.Live)))
+	mux.Handle("/readyz", metrics.Instrument("/readyz", http.HandlerFunc(checker.Ready)))
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: mux,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Health server stopped", "error", err)
+		}
+	}()
+	return srv
 }
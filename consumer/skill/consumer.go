@@ -1,83 +1,154 @@
 package skill
 
 import (
+	"context"
 	"encoding/json"
-	"log"
-	"os"
-	"os/signal"
-	"syscall"
+	"errors"
+	"log/slog"
+	"time"
 
-	"github.com/IBM/sarama"
+	"github.com/narunart-atise/skill-api-kafka/logging"
+	"github.com/narunart-atise/skill-api-kafka/metrics"
+	"github.com/narunart-atise/skill-api-kafka/tracing"
+	"github.com/narunart-atise/skill-api-kafka/transport"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type message struct {
-	Action string `json:"action"`
-	Key    string `json:"key"`
-	Data   Skill  `json:"data"`
+	Action  string `json:"action"`
+	Key     string `json:"key"`
+	EventID string `json:"event_id"`
+	Actor   string `json:"actor"`
+	Data    Skill  `json:"data"`
 }
 
 type Consumer struct {
-	consumer      sarama.Consumer
-	storage       storager
-	topic         string
+	subscriber    transport.Subscriber
 	actionHandler *ActionHandler
+	drainTimeout  time.Duration
 }
 
-func NewConsumer(broker, topic string, db storager) (*Consumer, error) {
-	config := sarama.NewConfig()
-	config.Consumer.Return.Errors = true
-	config.Consumer.Offsets.Initial = sarama.OffsetOldest
-
-	consumer, err := sarama.NewConsumer([]string{broker}, config)
-	if err != nil {
-		return nil, err
-	}
-
+// NewConsumer returns a consumer applying the messages of subscriber to db.
+// On shutdown the message in hand gets drainTimeout to be applied.
+func NewConsumer(subscriber transport.Subscriber, db Storager, drainTimeout time.Duration) *Consumer {
 	return &Consumer{
-		consumer:      consumer,
-		storage:       db,
-		topic:         topic,
+		subscriber:    subscriber,
 		actionHandler: NewActionHandler(db),
-	}, nil
-}
-
-func (c *Consumer) Consume() {
-	partitionConsumer, err := c.consumer.ConsumePartition(c.topic, 0, sarama.OffsetOldest)
-	if err != nil {
-		log.Fatalln(err)
+		drainTimeout:  drainTimeout,
 	}
-	defer partitionConsumer.Close()
+}
 
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+// Consume applies messages until ctx is cancelled. It then stops fetching
+// and returns once the message in hand is applied and acknowledged, or
+// abandoned after the drain timeout to be delivered again later.
+func (c *Consumer) Consume(ctx context.Context) error {
+	draining, abandon := context.WithCancel(context.WithoutCancel(ctx))
+	defer abandon()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-draining.Done():
+			return
+		}
+		select {
+		case <-time.After(c.drainTimeout):
+			abandon()
+		case <-draining.Done():
+		}
+	}()
 
 	consumed := 0
-ConsumerLoop:
-	for {
-		select {
-		case msg := <-partitionConsumer.Messages():
-			var message message
-			if err := json.Unmarshal(msg.Value, &message); err != nil {
-				log.Printf("Failed to unmarshal message: %v", err)
-				continue
-			}
+	err := c.subscriber.Subscribe(ctx, detach(draining, tracing.Handler(logging.Handler(func(ctx context.Context, msg transport.Message) error {
+		start := time.Now()
+		var message message
+		if err := json.Unmarshal(msg.Value, &message); err != nil {
+			slog.ErrorContext(ctx, "Failed to unmarshal message", "key", msg.Key, "error", err, logging.Payload(msg.Value))
+			metrics.ObserveMessage("unknown", "invalid", time.Since(start))
+			return nil
+		}
 
-			c.actionHandler.HandleAction(message)
-			consumed++
-			log.Printf("Consumed message: %s", msg.Value)
-		case err := <-partitionConsumer.Errors():
-			log.Printf("Error: %v", err)
-		case <-signals:
-			log.Println("Interrupt is detected")
-			break ConsumerLoop
+		ctx, span := tracing.Start(ctx, "HandleAction",
+			attribute.String("skill.action", message.Action),
+			attribute.String("skill.key", message.Key),
+			attribute.String("skill.event_id", message.EventID),
+		)
+		err := c.actionHandler.HandleAction(ctx, message)
+		tracing.End(span, err)
+		metrics.ObserveMessage(actionLabel(message.Action, err), outcome(err), time.Since(start))
+		// A message abandoned at shutdown, or that failed for a reason other
+		// than the command itself, such as a database error, is left
+		// unacknowledged, so that it is delivered again. Rejected commands
+		// would only be rejected again.
+		if err != nil && ctx.Err() != nil {
+			slog.WarnContext(ctx, "Abandoned message on shutdown", "action", message.Action, "key", message.Key, "event_id", message.EventID)
+			return ctx.Err()
 		}
-	}
+		if err != nil && !rejected(err) {
+			return err
+		}
+		consumed++
+		slog.InfoContext(ctx, "Consumed message",
+			"action", message.Action,
+			"key", message.Key,
+			"event_id", message.EventID,
+			"outcome", outcome(err),
+			"duration", time.Since(start),
+			logging.Payload(msg.Value),
+		)
+		return nil
+	}))))
+
+	slog.Info("Consumer stopped", "consumed", consumed)
+	return err
+}
 
-	log.Printf("Consumed: %d messages", consumed)
+// detach runs handle on a context that keeps the values of the one given by
+// the subscriber, such as the trace, but is only cancelled with draining:
+// the subscriber's is cancelled as soon as shutdown begins.
+func detach(draining context.Context, handle transport.Handler) transport.Handler {
+	return func(ctx context.Context, msg transport.Message) error {
+		ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		defer cancel()
+		defer context.AfterFunc(draining, cancel)()
+		return handle(ctx, msg)
+	}
 }
 
 func (c *Consumer) Close() {
-	if err := c.consumer.Close(); err != nil {
-		log.Printf("Failed to close consumer: %v", err)
+	if err := c.subscriber.Close(); err != nil {
+		slog.Error("Failed to close consumer", "error", err)
+	}
+}
+
+// rejected reports whether err refuses the command itself, so that handling
+// it again cannot succeed.
+func rejected(err error) bool {
+	return errors.Is(err, errUnknownAction) || errors.Is(err, errInvalidCommand) || errors.Is(err, errConflict) || errors.Is(err, errNotFound)
+}
+
+// outcome labels the result of handling a message in metrics.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, errUnknownAction):
+		return "unknown_action"
+	case errors.Is(err, errInvalidCommand):
+		return "invalid"
+	case errors.Is(err, errConflict):
+		return "conflict"
+	case errors.Is(err, errNotFound):
+		return "not_found"
+	default:
+		return "failed"
+	}
+}
+
+// actionLabel keeps unknown actions, which come from the wire, out of metric
+// labels.
+func actionLabel(action string, err error) string {
+	if errors.Is(err, errUnknownAction) {
+		return "unknown"
 	}
+	return action
 }
@@ -0,0 +1,210 @@
+package skill
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/memdb"
+	"github.com/narunart-atise/skill-api-kafka/transport"
+)
+
+// blockingStorage holds PostSkill until release is closed or its context is
+// cancelled, to stand for a slow database.
+type blockingStorage struct {
+	Storager
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s blockingStorage) PostSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	s.started <- struct{}{}
+	select {
+	case <-s.release:
+		return s.Storager.PostSkill(ctx, skill, actor)
+	case <-ctx.Done():
+		return Skill{}, ctx.Err()
+	}
+}
+
+func startConsumer(t *testing.T, drainTimeout time.Duration, keys ...string) (*transport.Bus, blockingStorage, context.CancelFunc, chan error) {
+	t.Helper()
+
+	bus := transport.NewBus(time.Millisecond)
+	for _, key := range keys {
+		value, _ := json.Marshal(message{Action: "Insert", Key: key, EventID: "event", Actor: "test", Data: Skill{Key: key, Name: key}})
+		if err := bus.Publisher().Publish(context.Background(), transport.Message{Key: key, Value: value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	storage := blockingStorage{Storager: NewMemoryStorage(memdb.New()), started: make(chan struct{}, 1), release: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewConsumer(bus.Subscriber(), storage, drainTimeout).Consume(ctx) }()
+
+	select {
+	case <-storage.started:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the first message to be handled")
+	}
+	return bus, storage, cancel, done
+}
+
+func lag(t *testing.T, bus *transport.Bus) int64 {
+	t.Helper()
+	status, err := bus.Subscriber().(transport.Checker).Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *status.Lag
+}
+
+func TestConsumeDrainsOnShutdown(t *testing.T) {
+	bus, storage, cancel, done := startConsumer(t, time.Second, "go", "rust")
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	close(storage.release)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Consume error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Consume to return once the message in hand was applied")
+	}
+
+	if _, err := storage.FindSkillByKey(context.Background(), "go"); err != nil {
+		t.Errorf("Expected the message in hand to be applied, got %v", err)
+	}
+	if got := lag(t, bus); got != 1 {
+		t.Errorf("Expected only the next message left queued, got %d", got)
+	}
+}
+
+func TestConsumeAbandonsAfterDrainTimeout(t *testing.T) {
+	bus, storage, cancel, done := startConsumer(t, 20*time.Millisecond, "go")
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Consume error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Consume to give up on the message in hand")
+	}
+
+	if _, err := storage.FindSkillByKey(context.Background(), "go"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected the abandoned message not to be applied, got %v", err)
+	}
+	if got := lag(t, bus); got != 1 {
+		t.Errorf("Expected the abandoned message to stay queued, got %d", got)
+	}
+}
+
+// flakyStorage fails the first PostSkill, to stand for a database that is
+// briefly unreachable.
+type flakyStorage struct {
+	Storager
+	calls *atomic.Int32
+}
+
+func (s flakyStorage) PostSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	if s.calls.Add(1) == 1 {
+		return Skill{}, errors.New("connection refused")
+	}
+	return s.Storager.PostSkill(ctx, skill, actor)
+}
+
+func TestConsumeRedeliversFailedMessage(t *testing.T) {
+	bus := transport.NewBus(time.Millisecond)
+	publish := func(m message) {
+		value, _ := json.Marshal(m)
+		if err := bus.Publisher().Publish(context.Background(), transport.Message{Key: m.Key, Value: value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The rejected update is acknowledged and not retried; the insert that
+	// failed on the database is delivered again and applied.
+	publish(message{Action: "UpdateName", Key: "rust", EventID: "event-1", Actor: "test", Data: Skill{Name: "Rust"}})
+	publish(message{Action: "Insert", Key: "go", EventID: "event-2", Actor: "test", Data: Skill{Key: "go", Name: "Go"}})
+
+	storage := flakyStorage{Storager: NewMemoryStorage(memdb.New()), calls: &atomic.Int32{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewConsumer(bus.Subscriber(), storage, time.Second).Consume(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for lag(t, bus) != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := lag(t, bus); got != 0 {
+		t.Fatalf("Expected every message to be acknowledged, got a lag of %d", got)
+	}
+	if got := storage.calls.Load(); got != 2 {
+		t.Errorf("Expected the insert to be delivered twice, got %d calls", got)
+	}
+	if _, err := storage.FindSkillByKey(context.Background(), "go"); err != nil {
+		t.Errorf("Expected the insert to be applied on redelivery, got %v", err)
+	}
+}
+
+func TestHandleActionRejectsInvalidCommand(t *testing.T) {
+	storage := NewMemoryStorage(memdb.New())
+	handler := NewActionHandler(storage)
+
+	for _, m := range []message{
+		{Action: "Insert", Key: "Go Lang", Data: Skill{Key: "Go Lang", Name: "Go"}},
+		{Action: "Insert", Key: "go", Data: Skill{Key: "go"}},
+		{Action: "UpdateTags", Key: "go"},
+	} {
+		if err := handler.HandleAction(context.Background(), m); !errors.Is(err, errInvalidCommand) || outcome(err) != "invalid" {
+			t.Errorf("Expected %s %q to be rejected, got %v", m.Action, m.Key, err)
+		}
+	}
+	if _, err := storage.FindSkillByKey(context.Background(), "go"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected nothing to be stored, got %v", err)
+	}
+}
+
+func TestHandleActionChecksStoredSkill(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage(memdb.New())
+	handler := NewActionHandler(storage)
+	for _, key := range []string{"go", "cobol"} {
+		if _, err := storage.PostSkill(ctx, Skill{Key: key, Name: key}, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	storage.DeleteSkill(ctx, "cobol", "test")
+
+	for _, tc := range []struct {
+		message message
+		outcome string
+	}{
+		{message{Action: "Insert", Key: "go", Data: Skill{Key: "go", Name: "Go"}}, "conflict"},
+		{message{Action: "Insert", Key: "cobol", Data: Skill{Key: "cobol", Name: "COBOL"}}, "conflict"},
+		{message{Action: "UpdateName", Key: "rust", Data: Skill{Name: "Rust"}}, "not_found"},
+		{message{Action: "UpdateName", Key: "cobol", Data: Skill{Name: "COBOL"}}, "not_found"},
+		{message{Action: "DeleteSkill", Key: "rust"}, "not_found"},
+		{message{Action: "RestoreSkill", Key: "go"}, "conflict"},
+		{message{Action: "RestoreSkill", Key: "rust"}, "not_found"},
+		{message{Action: "RestoreSkill", Key: "cobol"}, "ok"},
+		{message{Action: "Insert", Key: "rust", Data: Skill{Key: "rust", Name: "Rust"}}, "ok"},
+	} {
+		if got := outcome(handler.HandleAction(ctx, tc.message)); got != tc.outcome {
+			t.Errorf("%s %s: expected %s, got %s", tc.message.Action, tc.message.Key, tc.outcome, got)
+		}
+	}
+}
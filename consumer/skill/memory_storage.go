@@ -0,0 +1,162 @@
+package skill
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/memdb"
+)
+
+type memoryStorage struct {
+	db *memdb.DB
+}
+
+// NewMemoryStorage keeps skills in db instead of a database server. It
+// behaves like NewStorage, down to returning sql.ErrNoRows for missing skills.
+func NewMemoryStorage(db *memdb.DB) *memoryStorage {
+	return &memoryStorage{db}
+}
+
+func (s memoryStorage) FindAllSkill(ctx context.Context) ([]Skill, error) {
+	var skills []Skill
+	err := s.db.View(func(tx memdb.Tx) error {
+		for _, row := range tx.Skills() {
+			if row.DeletedAt == nil {
+				skills = append(skills, skillFromRow(row))
+			}
+		}
+		return nil
+	})
+	return skills, err
+}
+
+func (s memoryStorage) FindSkillByKey(ctx context.Context, key string) (Skill, error) {
+	return s.findSkill(key, false)
+}
+
+func (s memoryStorage) FindDeletedSkillByKey(ctx context.Context, key string) (Skill, error) {
+	return s.findSkill(key, true)
+}
+
+func (s memoryStorage) findSkill(key string, deleted bool) (Skill, error) {
+	var skill Skill
+	err := s.db.View(func(tx memdb.Tx) error {
+		row, ok := tx.Skill(key)
+		if !ok || (row.DeletedAt != nil) != deleted {
+			return sql.ErrNoRows
+		}
+		skill = skillFromRow(row)
+		return nil
+	})
+	return skill, err
+}
+
+func (s memoryStorage) PostSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	err := s.db.Update(func(tx memdb.Tx) error {
+		return tx.InsertSkill(memdb.Skill{
+			Key:         skill.Key,
+			Name:        skill.Name,
+			Description: skill.Description,
+			Logo:        skill.Logo,
+			Tags:        skill.Tags,
+		}, actor)
+	})
+	if err != nil {
+		return Skill{}, err
+	}
+	return s.FindSkillByKey(ctx, skill.Key)
+}
+
+// edit applies change to the skill with the given key and stamps it as
+// updated by actor. Deleted skills are left alone unless includeDeleted is
+// set. Like an UPDATE matching no rows, a missing skill is only reported by
+// the read that follows.
+func (s memoryStorage) edit(ctx context.Context, key, actor string, includeDeleted bool, change func(row *memdb.Skill)) (Skill, error) {
+	_ = s.db.Update(func(tx memdb.Tx) error {
+		tx.EditSkill(key, actor, includeDeleted, change)
+		return nil
+	})
+	return s.FindSkillByKey(ctx, key)
+}
+
+func (s memoryStorage) EditSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	return s.edit(ctx, skill.Key, actor, false, func(row *memdb.Skill) {
+		row.Name, row.Description, row.Logo, row.Tags = skill.Name, skill.Description, skill.Logo, skill.Tags
+	})
+}
+
+func (s memoryStorage) EditSkillName(ctx context.Context, key string, name string, actor string) (Skill, error) {
+	return s.edit(ctx, key, actor, false, func(row *memdb.Skill) { row.Name = name })
+}
+
+func (s memoryStorage) EditSkillDescription(ctx context.Context, key, description string, actor string) (Skill, error) {
+	return s.edit(ctx, key, actor, false, func(row *memdb.Skill) { row.Description = description })
+}
+
+func (s memoryStorage) EditSkillLogo(ctx context.Context, key, logo string, actor string) (Skill, error) {
+	return s.edit(ctx, key, actor, false, func(row *memdb.Skill) { row.Logo = logo })
+}
+
+func (s memoryStorage) EditSkillTags(ctx context.Context, key string, Tags []string, actor string) (Skill, error) {
+	return s.edit(ctx, key, actor, false, func(row *memdb.Skill) { row.Tags = Tags })
+}
+
+func (s memoryStorage) DeleteSkill(ctx context.Context, rowKey, actor string) string {
+	_, _ = s.edit(ctx, rowKey, actor, false, func(row *memdb.Skill) {
+		now := time.Now().UTC()
+		row.DeletedAt = &now
+	})
+	return "success"
+}
+
+func (s memoryStorage) RestoreSkill(ctx context.Context, key, actor string) (Skill, error) {
+	return s.edit(ctx, key, actor, true, func(row *memdb.Skill) { row.DeletedAt = nil })
+}
+
+// PurgeDeletedSkills hard-deletes skills that were soft-deleted before the
+// given time and returns how many were removed.
+func (s memoryStorage) PurgeDeletedSkills(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := s.db.Update(func(tx memdb.Tx) error {
+		for _, row := range tx.Skills() {
+			if row.DeletedAt != nil && row.DeletedAt.Before(before) {
+				tx.DeleteSkill(row.Key)
+				purged++
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
+
+func (s memoryStorage) PostRevision(ctx context.Context, eventID, action, actor string, skill Skill) error {
+	return s.db.Update(func(tx memdb.Tx) error {
+		tx.AddRevision(memdb.Revision{
+			Key:         skill.Key,
+			EventID:     eventID,
+			Action:      action,
+			Actor:       actor,
+			Name:        skill.Name,
+			Description: skill.Description,
+			Logo:        skill.Logo,
+			Tags:        skill.Tags,
+			CreatedAt:   time.Now().UTC(),
+		})
+		return nil
+	})
+}
+
+func skillFromRow(row memdb.Skill) Skill {
+	return Skill{
+		Key:         row.Key,
+		Name:        row.Name,
+		Description: row.Description,
+		Logo:        row.Logo,
+		Tags:        row.Tags,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+		CreatedBy:   row.CreatedBy,
+		UpdatedBy:   row.UpdatedBy,
+	}
+}
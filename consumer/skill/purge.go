@@ -0,0 +1,45 @@
+package skill
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Purger periodically hard-deletes skills that have been in the trash for
+// longer than the retention period.
+type Purger struct {
+	storage   Storager
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurger(storage Storager, retention, interval time.Duration) *Purger {
+	return &Purger{storage: storage, retention: retention, interval: interval}
+}
+
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	purged, err := p.storage.PurgeDeletedSkills(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to purge deleted skills", "error", err)
+		return
+	}
+	if purged > 0 {
+		slog.InfoContext(ctx, "Purged deleted skills", "skills", purged)
+	}
+}
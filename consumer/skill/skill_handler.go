@@ -1,48 +1,166 @@
 package skill
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/narunart-atise/skill-api-kafka/validate"
+)
+
+var (
+	errUnknownAction  = errors.New("unknown action")
+	errInvalidCommand = errors.New("invalid command")
+	errConflict       = errors.New("conflicts with the stored skill")
+	errNotFound       = errors.New("skill not found")
 )
 
 type ActionHandler struct {
-	storage storager
+	storage Storager
 }
 
-func NewActionHandler(storage storager) *ActionHandler {
+func NewActionHandler(storage Storager) *ActionHandler {
 	return &ActionHandler{storage: storage}
 }
 
-func (a *ActionHandler) HandleAction(message message) {
+// HandleAction applies message to the storage and records a revision. Errors
+// are logged here; they are returned for metrics and so that failures other
+// than rejections get the message delivered again.
+//
+// The message is validated again, as the API is not the only possible
+// producer, and rejected when invalid or when the stored skill does not allow
+// it.
+func (a *ActionHandler) HandleAction(ctx context.Context, message message) error {
+	// Insert and Update store the skill under the key of their data.
+	key := message.Key
+	if message.Action == "Insert" || message.Action == "Update" {
+		key = message.Data.Key
+	}
+	fields := validate.Skill{Key: key, Name: message.Data.Name, Description: message.Data.Description, Logo: message.Data.Logo, Tags: message.Data.Tags}
+	if err := validate.Command(message.Action, fields); err != nil {
+		slog.WarnContext(ctx, "Rejected invalid command", "action", message.Action, "key", message.Key, "event_id", message.EventID, "error", err)
+		return fmt.Errorf("%w: %w", errInvalidCommand, err)
+	}
+	if err := a.check(ctx, message.Action, key); err != nil {
+		if errors.Is(err, errConflict) || errors.Is(err, errNotFound) {
+			slog.WarnContext(ctx, "Rejected command", "action", message.Action, "key", key, "event_id", message.EventID, "error", err)
+		} else {
+			slog.ErrorContext(ctx, "Failed to read skill", "key", key, "error", err)
+		}
+		return err
+	}
+
+	var (
+		skill Skill
+		err   error
+	)
+
 	switch message.Action {
 	case "Insert":
-		if _, err := a.storage.PostSkill(message.Data); err != nil {
-			log.Printf("Failed to insert skill: %v", err)
+		if skill, err = a.storage.PostSkill(ctx, message.Data, message.Actor); err != nil {
+			slog.ErrorContext(ctx, "Failed to insert skill", "key", message.Key, "error", err)
+			return err
 		}
 	case "Update":
-		if _, err := a.storage.EditSkill(message.Data); err != nil {
-			log.Printf("Failed to update skill: %v", err)
+		if skill, err = a.storage.EditSkill(ctx, message.Data, message.Actor); err != nil {
+			slog.ErrorContext(ctx, "Failed to update skill", "key", message.Key, "error", err)
+			return err
 		}
 	case "UpdateName":
-		if _, err := a.storage.EditSkillName(message.Key, message.Data.Name); err != nil {
-			log.Printf("Failed to update skill name: %v", err)
+		if skill, err = a.storage.EditSkillName(ctx, message.Key, message.Data.Name, message.Actor); err != nil {
+			slog.ErrorContext(ctx, "Failed to update skill name", "key", message.Key, "error", err)
+			return err
 		}
 	case "UpdateDescription":
-		if _, err := a.storage.EditSkillDescription(message.Key, message.Data.Description); err != nil {
-			log.Printf("Failed to update skill description: %v", err)
+		if skill, err = a.storage.EditSkillDescription(ctx, message.Key, message.Data.Description, message.Actor); err != nil {
+			slog.ErrorContext(ctx, "Failed to update skill description", "key", message.Key, "error", err)
+			return err
 		}
 	case "UpdateLogo":
-		if _, err := a.storage.EditSkillLogo(message.Key, message.Data.Logo); err != nil {
-			log.Printf("Failed to update skill logo: %v", err)
+		if skill, err = a.storage.EditSkillLogo(ctx, message.Key, message.Data.Logo, message.Actor); err != nil {
+			slog.ErrorContext(ctx, "Failed to update skill logo", "key", message.Key, "error", err)
+			return err
 		}
 	case "UpdateTags":
-		if _, err := a.storage.EditSkillTags(message.Key, message.Data.Tags); err != nil {
-			log.Printf("Failed to update skill tags: %v", err)
+		if skill, err = a.storage.EditSkillTags(ctx, message.Key, message.Data.Tags, message.Actor); err != nil {
+			slog.ErrorContext(ctx, "Failed to update skill tags", "key", message.Key, "error", err)
+			return err
 		}
 	case "DeleteSkill":
-		if res := a.storage.DeleteSkill(message.Key); res != "success" {
-			log.Printf("Failed to delete skill")
+		// Keep the last known state so the delete can be reverted.
+		if skill, err = a.storage.FindSkillByKey(ctx, message.Key); err != nil {
+			slog.ErrorContext(ctx, "Failed to delete skill", "key", message.Key, "error", err)
+			return err
+		}
+		if res := a.storage.DeleteSkill(ctx, message.Key, message.Actor); res != "success" {
+			slog.ErrorContext(ctx, "Failed to delete skill", "key", message.Key)
+			return errors.New("delete skill failed")
+		}
+	case "RestoreSkill":
+		if skill, err = a.storage.RestoreSkill(ctx, message.Key, message.Actor); err != nil {
+			slog.ErrorContext(ctx, "Failed to restore skill", "key", message.Key, "error", err)
+			return err
 		}
 	default:
-		log.Printf("Unknown action: %s", message.Action)
+		slog.WarnContext(ctx, "Unknown action", "action", message.Action, "key", message.Key)
+		return fmt.Errorf("%w: %s", errUnknownAction, message.Action)
+	}
+
+	if err := a.storage.PostRevision(ctx, message.EventID, message.Action, message.Actor, skill); err != nil {
+		slog.ErrorContext(ctx, "Failed to record skill revision", "key", message.Key, "error", err)
+		return err
+	}
+	return nil
+}
+
+// check refuses action when the stored state of the skill with key does not
+// allow it: Insert needs a free key, even of a deleted skill, RestoreSkill a
+// deleted skill and the other actions a live one. The API may check the same
+// before publishing, but on a state read earlier; messages for one key are
+// consumed in order, so the state read here is the one action applies to.
+func (a *ActionHandler) check(ctx context.Context, action, key string) error {
+	switch action {
+	case "Insert", "RestoreSkill", "Update", "UpdateName", "UpdateDescription", "UpdateLogo", "UpdateTags", "DeleteSkill":
+	default:
+		return nil
+	}
+
+	live, deleted, err := a.state(ctx, key)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "Insert":
+		if live {
+			return fmt.Errorf("%w: %s already exists", errConflict, key)
+		}
+		if deleted {
+			return fmt.Errorf("%w: %s is deleted", errConflict, key)
+		}
+	case "RestoreSkill":
+		if live {
+			return fmt.Errorf("%w: %s is not deleted", errConflict, key)
+		}
+		if !deleted {
+			return fmt.Errorf("%w: %s", errNotFound, key)
+		}
+	default:
+		if !live {
+			return fmt.Errorf("%w: %s", errNotFound, key)
+		}
+	}
+	return nil
+}
+
+// state tells whether key names a live skill, a deleted one or neither.
+func (a *ActionHandler) state(ctx context.Context, key string) (live, deleted bool, err error) {
+	if _, err = a.storage.FindSkillByKey(ctx, key); !errors.Is(err, sql.ErrNoRows) {
+		return err == nil, false, err
+	}
+	if _, err = a.storage.FindDeletedSkillByKey(ctx, key); !errors.Is(err, sql.ErrNoRows) {
+		return false, err == nil, err
 	}
+	return false, false, nil
 }
@@ -1,34 +1,45 @@
 package skill
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"time"
 
-	"github.com/lib/pq"
+	"github.com/narunart-atise/skill-api-kafka/logging"
+	"github.com/narunart-atise/skill-api-kafka/sqltags"
 )
 
 type storage struct {
 	db *sql.DB
 }
 
-type storager interface {
-	FindAllSkill() ([]Skill, error)
-	FindSkillByKey(key string) (Skill, error)
-	PostSkill(skill Skill) (Skill, error)
-	EditSkill(skill Skill) (Skill, error)
-	EditSkillName(key string, name string) (Skill, error)
-	EditSkillDescription(key, description string) (Skill, error)
-	EditSkillLogo(key, logo string) (Skill, error)
-	EditSkillTags(key string, Tags []string) (Skill, error)
-	DeleteSkill(rowKey string) string
+// Storager applies skill commands and records their revisions. NewStorage
+// implements it on a Postgres or SQLite database and NewMemoryStorage on an
+// in-memory one.
+type Storager interface {
+	FindAllSkill(ctx context.Context) ([]Skill, error)
+	FindSkillByKey(ctx context.Context, key string) (Skill, error)
+	FindDeletedSkillByKey(ctx context.Context, key string) (Skill, error)
+	PostSkill(ctx context.Context, skill Skill, actor string) (Skill, error)
+	EditSkill(ctx context.Context, skill Skill, actor string) (Skill, error)
+	EditSkillName(ctx context.Context, key string, name string, actor string) (Skill, error)
+	EditSkillDescription(ctx context.Context, key, description string, actor string) (Skill, error)
+	EditSkillLogo(ctx context.Context, key, logo string, actor string) (Skill, error)
+	EditSkillTags(ctx context.Context, key string, Tags []string, actor string) (Skill, error)
+	DeleteSkill(ctx context.Context, rowKey, actor string) string
+	RestoreSkill(ctx context.Context, key, actor string) (Skill, error)
+	PurgeDeletedSkills(ctx context.Context, before time.Time) (int64, error)
+	PostRevision(ctx context.Context, eventID, action, actor string, skill Skill) error
 }
 
+const skillColumns = "key, name, description,logo,tags,created_at,updated_at,created_by,updated_by"
+
 func NewStorage(db *sql.DB) *storage {
 	return &storage{db}
 }
 
-func (s storage) FindAllSkill() ([]Skill, error) {
-	rows, err := s.db.Query("SELECT key, name, description,logo,tags FROM skill")
+func (s storage) FindAllSkill(ctx context.Context) ([]Skill, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+skillColumns+" FROM skill WHERE deleted_at IS NULL")
 	if err != nil {
 		return []Skill{}, nil
 	}
@@ -36,100 +47,123 @@ func (s storage) FindAllSkill() ([]Skill, error) {
 	var Skills []Skill
 	for rows.Next() {
 		var skill Skill
-		err := rows.Scan(&skill.Key, &skill.Name, &skill.Description, &skill.Logo, pq.Array(&skill.Tags))
+		err := rows.Scan(&skill.Key, &skill.Name, &skill.Description, &skill.Logo, (*sqltags.Array)(&skill.Tags),
+			&skill.CreatedAt, &skill.UpdatedAt, &skill.CreatedBy, &skill.UpdatedBy)
 		if err != nil {
-			log.Fatal("can't Scan row into variable", err)
+			logging.Fatal("can't Scan row into variable", "error", err)
 		}
 
-		Skills = append(Skills, Skill{
-			Key:         skill.Key,
-			Name:        skill.Name,
-			Description: skill.Description,
-			Logo:        skill.Logo,
-			Tags:        skill.Tags,
-		})
+		Skills = append(Skills, skill)
 	}
 
 	return Skills, nil
 }
 
-func (s storage) FindSkillByKey(key string) (Skill, error) {
-	q := "SELECT key, name, description,logo,tags FROM skill WHERE key=$1"
-	row := s.db.QueryRow(q, key)
+func (s storage) FindSkillByKey(ctx context.Context, key string) (Skill, error) {
+	q := "SELECT " + skillColumns + " FROM skill WHERE key=$1 AND deleted_at IS NULL"
+	return scanSkill(s.db.QueryRowContext(ctx, q, key))
+}
+
+func (s storage) FindDeletedSkillByKey(ctx context.Context, key string) (Skill, error) {
+	q := "SELECT " + skillColumns + " FROM skill WHERE key=$1 AND deleted_at IS NOT NULL"
+	return scanSkill(s.db.QueryRowContext(ctx, q, key))
+}
 
+func scanSkill(row *sql.Row) (Skill, error) {
 	var skill Skill
-	err := row.Scan(&skill.Key, &skill.Name, &skill.Description, &skill.Logo, pq.Array(&skill.Tags))
+	err := row.Scan(&skill.Key, &skill.Name, &skill.Description, &skill.Logo, (*sqltags.Array)(&skill.Tags),
+		&skill.CreatedAt, &skill.UpdatedAt, &skill.CreatedBy, &skill.UpdatedBy)
 	if err != nil {
 		return Skill{}, err
 	}
 
-	return Skill{
-		Key:         skill.Key,
-		Name:        skill.Name,
-		Description: skill.Description,
-		Logo:        skill.Logo,
-		Tags:        skill.Tags,
-	}, nil
+	return skill, nil
 }
 
-func (s storage) PostSkill(skill Skill) (Skill, error) {
-	q := "INSERT INTO skill (key,name, description,logo,tags) values ($1, $2,$3,$4,$5) RETURNING key"
-	row := s.db.QueryRow(q, skill.Key, skill.Name, skill.Description, skill.Logo, pq.Array(skill.Tags))
+func (s storage) PostSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	q := "INSERT INTO skill (key,name, description,logo,tags,created_at,updated_at,created_by,updated_by) values ($1, $2,$3,$4,$5,$6,$6,$7,$7) RETURNING key"
+	row := s.db.QueryRowContext(ctx, q, skill.Key, skill.Name, skill.Description, skill.Logo, sqltags.Array(skill.Tags), time.Now().UTC(), actor)
 
 	var keyid string
 	err := row.Scan(&keyid)
 	if err != nil {
 		return Skill{}, err
 	}
-	return s.FindSkillByKey(keyid)
+	return s.FindSkillByKey(ctx, keyid)
 }
 
-func (s storage) EditSkill(skill Skill) (Skill, error) {
-	q := "UPDATE skill SET name=$2, description=$3, logo=$4, tags=$5 WHERE key=$1;"
-	if _, err := s.db.Exec(q, skill.Key, skill.Name, skill.Description, skill.Logo, pq.Array(skill.Tags)); err != nil {
+func (s storage) EditSkill(ctx context.Context, skill Skill, actor string) (Skill, error) {
+	q := "UPDATE skill SET name=$2, description=$3, logo=$4, tags=$5, updated_at=$6, updated_by=$7 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, skill.Key, skill.Name, skill.Description, skill.Logo, sqltags.Array(skill.Tags), time.Now().UTC(), actor); err != nil {
 		return Skill{}, err
 	}
 
-	return s.FindSkillByKey(skill.Key)
+	return s.FindSkillByKey(ctx, skill.Key)
 }
 
-func (s storage) EditSkillName(key string, name string) (Skill, error) {
-	q := "UPDATE skill SET name=$2 WHERE key=$1;"
-	if _, err := s.db.Exec(q, key, name); err != nil {
+func (s storage) EditSkillName(ctx context.Context, key string, name string, actor string) (Skill, error) {
+	q := "UPDATE skill SET name=$2, updated_at=$3, updated_by=$4 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, key, name, time.Now().UTC(), actor); err != nil {
 		return Skill{}, err
 	}
-	return s.FindSkillByKey(key)
+	return s.FindSkillByKey(ctx, key)
 }
 
-func (s storage) EditSkillDescription(key, description string) (Skill, error) {
-	q := "UPDATE skill SET description=$2 WHERE key=$1;"
-	if _, err := s.db.Exec(q, key, description); err != nil {
+func (s storage) EditSkillDescription(ctx context.Context, key, description string, actor string) (Skill, error) {
+	q := "UPDATE skill SET description=$2, updated_at=$3, updated_by=$4 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, key, description, time.Now().UTC(), actor); err != nil {
 		return Skill{}, err
 	}
-	return s.FindSkillByKey(key)
+	return s.FindSkillByKey(ctx, key)
 }
 
-func (s storage) EditSkillLogo(key, logo string) (Skill, error) {
-	q := "UPDATE skill SET logo=$2 WHERE key=$1;"
-	if _, err := s.db.Exec(q, key, logo); err != nil {
+func (s storage) EditSkillLogo(ctx context.Context, key, logo string, actor string) (Skill, error) {
+	q := "UPDATE skill SET logo=$2, updated_at=$3, updated_by=$4 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, key, logo, time.Now().UTC(), actor); err != nil {
 		return Skill{}, err
 	}
-	return s.FindSkillByKey(key)
+	return s.FindSkillByKey(ctx, key)
 }
 
-func (s storage) EditSkillTags(key string, Tags []string) (Skill, error) {
-	q := "UPDATE skill SET tags=$2 WHERE key=$1;"
-	if _, err := s.db.Exec(q, key, pq.Array(Tags)); err != nil {
+func (s storage) EditSkillTags(ctx context.Context, key string, Tags []string, actor string) (Skill, error) {
+	q := "UPDATE skill SET tags=$2, updated_at=$3, updated_by=$4 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, key, sqltags.Array(Tags), time.Now().UTC(), actor); err != nil {
 		return Skill{}, err
 	}
-	return s.FindSkillByKey(key)
+	return s.FindSkillByKey(ctx, key)
 }
 
-func (s storage) DeleteSkill(rowKey string) string {
-	q := "DELETE FROM skill WHERE key=$1;"
-	if _, err := s.db.Exec(q, rowKey); err != nil {
+func (s storage) DeleteSkill(ctx context.Context, rowKey, actor string) string {
+	q := "UPDATE skill SET deleted_at=$2, updated_at=$2, updated_by=$3 WHERE key=$1 AND deleted_at IS NULL;"
+	if _, err := s.db.ExecContext(ctx, q, rowKey, time.Now().UTC(), actor); err != nil {
 		return "fail"
 	}
 
 	return "success"
 }
+
+func (s storage) RestoreSkill(ctx context.Context, key, actor string) (Skill, error) {
+	q := "UPDATE skill SET deleted_at=NULL, updated_at=$2, updated_by=$3 WHERE key=$1;"
+	if _, err := s.db.ExecContext(ctx, q, key, time.Now().UTC(), actor); err != nil {
+		return Skill{}, err
+	}
+	return s.FindSkillByKey(ctx, key)
+}
+
+// PurgeDeletedSkills hard-deletes skills that were soft-deleted before the
+// given time and returns how many rows were removed.
+func (s storage) PurgeDeletedSkills(ctx context.Context, before time.Time) (int64, error) {
+	q := "DELETE FROM skill WHERE deleted_at IS NOT NULL AND deleted_at < $1;"
+	res, err := s.db.ExecContext(ctx, q, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s storage) PostRevision(ctx context.Context, eventID, action, actor string, skill Skill) error {
+	q := `INSERT INTO skill_revision (key, rev, event_id, action, actor, name, description, logo, tags, created_at)
+	SELECT $1, COALESCE(MAX(rev), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9 FROM skill_revision WHERE key=$1`
+	_, err := s.db.ExecContext(ctx, q, skill.Key, eventID, action, actor, skill.Name, skill.Description, skill.Logo, sqltags.Array(skill.Tags), time.Now().UTC())
+	return err
+}
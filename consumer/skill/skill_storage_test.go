@@ -0,0 +1,290 @@
+package skill
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/consumer/database"
+	"github.com/narunart-atise/skill-api-kafka/memdb"
+	"github.com/narunart-atise/skill-api-kafka/migrations"
+	"github.com/narunart-atise/skill-api-kafka/sqltags"
+)
+
+// revisionRow is the part of a stored revision the tests look at.
+type revisionRow struct {
+	Rev    int
+	Action string
+	Name   string
+	Tags   []string
+}
+
+// storageBackend is a Storager under test with empty tables. revisions reads
+// back what PostRevision stored, as the consumer never reads them itself.
+type storageBackend struct {
+	storage   Storager
+	revisions func(key string) ([]revisionRow, error)
+}
+
+// forEachBackend runs test against every Storager implementation. Postgres
+// is only tested when TEST_DATABASE_URL points at a database it may wipe.
+func forEachBackend(t *testing.T, test func(t *testing.T, b storageBackend)) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) storageBackend
+	}{
+		{"sqlite", func(t *testing.T) storageBackend {
+			db, closeDB := database.NewSQLite(":memory:")
+			t.Cleanup(closeDB)
+			return sqlBackend(t, db)
+		}},
+		{"memory", func(t *testing.T) storageBackend {
+			mem := memdb.New()
+			return storageBackend{
+				storage: NewMemoryStorage(mem),
+				revisions: func(key string) ([]revisionRow, error) {
+					var rows []revisionRow
+					err := mem.View(func(tx memdb.Tx) error {
+						for _, r := range tx.Revisions(key) {
+							rows = append(rows, revisionRow{r.Rev, r.Action, r.Name, r.Tags})
+						}
+						return nil
+					})
+					return rows, err
+				},
+			}
+		}},
+		{"postgres", func(t *testing.T) storageBackend {
+			url := os.Getenv("TEST_DATABASE_URL")
+			if url == "" {
+				t.Skip("TEST_DATABASE_URL is not set")
+			}
+			db, closeDB := database.NewPostgres(url)
+			t.Cleanup(closeDB)
+
+			m, err := migrations.New(db)
+			if err != nil {
+				t.Fatalf("can't load migrations: %v", err)
+			}
+			if err := m.Up(context.Background()); err != nil {
+				t.Fatalf("can't migrate: %v", err)
+			}
+			return sqlBackend(t, db)
+		}},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.open(t))
+		})
+	}
+}
+
+func sqlBackend(t *testing.T, db *sql.DB) storageBackend {
+	if _, err := db.Exec("DELETE FROM skill"); err != nil {
+		t.Fatalf("can't clear skills: %v", err)
+	}
+	if _, err := db.Exec("DELETE FROM skill_revision"); err != nil {
+		t.Fatalf("can't clear revisions: %v", err)
+	}
+
+	return storageBackend{
+		storage: NewStorage(db),
+		revisions: func(key string) ([]revisionRow, error) {
+			rows, err := db.Query("SELECT rev, action, name, tags FROM skill_revision WHERE key=$1 ORDER BY rev", key)
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+
+			var revisions []revisionRow
+			for rows.Next() {
+				var r revisionRow
+				if err := rows.Scan(&r.Rev, &r.Action, &r.Name, (*sqltags.Array)(&r.Tags)); err != nil {
+					return nil, err
+				}
+				revisions = append(revisions, r)
+			}
+			return revisions, rows.Err()
+		},
+	}
+}
+
+func TestSkillStorage(t *testing.T) {
+	forEachBackend(t, testSkillStorage)
+}
+
+func testSkillStorage(t *testing.T, b storageBackend) {
+	storage := b.storage
+
+	testSkill := Skill{
+		Key:         "test-skill",
+		Name:        "Test Skill",
+		Description: "This is a test skill",
+		Logo:        "test-logo-url",
+		Tags:        []string{"tag1", "tag2"},
+	}
+
+	t.Run("PostSkill", func(t *testing.T) {
+		createdSkill, err := storage.PostSkill(context.Background(), testSkill, "tester")
+		if err != nil {
+			t.Fatalf("PostSkill error: %v", err)
+		}
+		if createdSkill.CreatedBy != "tester" || createdSkill.UpdatedBy != "tester" || createdSkill.CreatedAt.IsZero() {
+			t.Errorf("Expected audit columns to be set, got %v", createdSkill)
+		}
+
+		createdSkill.CreatedAt, createdSkill.UpdatedAt = time.Time{}, time.Time{}
+		createdSkill.CreatedBy, createdSkill.UpdatedBy = "", ""
+		if !reflect.DeepEqual(createdSkill, testSkill) {
+			t.Errorf("Expected skill %v, got %v", testSkill, createdSkill)
+		}
+
+		if _, err := storage.PostSkill(context.Background(), testSkill, "tester"); err == nil {
+			t.Error("Expected an error posting an existing key")
+		}
+	})
+
+	t.Run("FindAllSkill", func(t *testing.T) {
+		skills, err := storage.FindAllSkill(context.Background())
+		if err != nil {
+			t.Fatalf("FindAllSkill error: %v", err)
+		}
+		if len(skills) != 1 || skills[0].Key != testSkill.Key {
+			t.Errorf("Expected only %s, got %v", testSkill.Key, skills)
+		}
+	})
+
+	t.Run("FindSkillByKey", func(t *testing.T) {
+		foundSkill, err := storage.FindSkillByKey(context.Background(), testSkill.Key)
+		if err != nil {
+			t.Fatalf("FindSkillByKey error: %v", err)
+		}
+		if foundSkill.Key != testSkill.Key {
+			t.Errorf("Expected key %s, got %s", testSkill.Key, foundSkill.Key)
+		}
+
+		if _, err := storage.FindSkillByKey(context.Background(), "no-such-skill"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+
+	t.Run("EditSkill", func(t *testing.T) {
+		edit := Skill{Key: testSkill.Key, Name: "Edit Skill", Description: "Edited", Logo: "edit-logo", Tags: []string{"edit"}}
+		updatedSkill, err := storage.EditSkill(context.Background(), edit, "editor")
+		if err != nil {
+			t.Fatalf("EditSkill error: %v", err)
+		}
+		if updatedSkill.Name != edit.Name || updatedSkill.CreatedBy != "tester" || updatedSkill.UpdatedBy != "editor" {
+			t.Errorf("Expected skill edited by editor, got %v", updatedSkill)
+		}
+	})
+
+	t.Run("EditSkillFields", func(t *testing.T) {
+		if s, err := storage.EditSkillName(context.Background(), testSkill.Key, "New Name", "editor"); err != nil || s.Name != "New Name" {
+			t.Errorf("EditSkillName got %v, %v", s, err)
+		}
+		if s, err := storage.EditSkillDescription(context.Background(), testSkill.Key, "New Description", "editor"); err != nil || s.Description != "New Description" {
+			t.Errorf("EditSkillDescription got %v, %v", s, err)
+		}
+		if s, err := storage.EditSkillLogo(context.Background(), testSkill.Key, "New Logo", "editor"); err != nil || s.Logo != "New Logo" {
+			t.Errorf("EditSkillLogo got %v, %v", s, err)
+		}
+
+		tags := []string{"with,comma", `with "quote"`, `back\slash`, "with space", "NULL", ""}
+		if s, err := storage.EditSkillTags(context.Background(), testSkill.Key, tags, "editor"); err != nil || !reflect.DeepEqual(s.Tags, tags) {
+			t.Errorf("EditSkillTags got %q, %v", s.Tags, err)
+		}
+	})
+
+	t.Run("DeleteSkill", func(t *testing.T) {
+		if result := storage.DeleteSkill(context.Background(), testSkill.Key, "editor"); result != "success" {
+			t.Errorf("DeleteSkill failed, expected 'success', got '%s'", result)
+		}
+		if _, err := storage.FindSkillByKey(context.Background(), testSkill.Key); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected deleted skill to be hidden, got %v", err)
+		}
+		if deleted, err := storage.FindDeletedSkillByKey(context.Background(), testSkill.Key); err != nil || deleted.Name != "New Name" {
+			t.Errorf("Expected deleted skill to be found in the trash, got %v, %v", deleted, err)
+		}
+		if _, err := storage.EditSkillName(context.Background(), testSkill.Key, "Ghost", "editor"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected deleted skill not to be editable, got %v", err)
+		}
+		if skills, _ := storage.FindAllSkill(context.Background()); len(skills) != 0 {
+			t.Errorf("Expected no live skills, got %v", skills)
+		}
+	})
+
+	t.Run("RestoreSkill", func(t *testing.T) {
+		restored, err := storage.RestoreSkill(context.Background(), testSkill.Key, "restorer")
+		if err != nil {
+			t.Fatalf("RestoreSkill error: %v", err)
+		}
+		if restored.Name != "New Name" || restored.UpdatedBy != "restorer" {
+			t.Errorf("Expected the last state to be restored by restorer, got %v", restored)
+		}
+
+		if _, err := storage.RestoreSkill(context.Background(), "no-such-skill", "restorer"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+
+	t.Run("PurgeDeletedSkills", func(t *testing.T) {
+		storage.DeleteSkill(context.Background(), testSkill.Key, "editor")
+
+		purged, err := storage.PurgeDeletedSkills(context.Background(), time.Now().Add(-time.Hour))
+		if err != nil || purged != 0 {
+			t.Errorf("Expected a recent delete to be kept, purged %d, %v", purged, err)
+		}
+
+		purged, err = storage.PurgeDeletedSkills(context.Background(), time.Now().Add(time.Hour))
+		if err != nil || purged != 1 {
+			t.Errorf("Expected one skill purged, purged %d, %v", purged, err)
+		}
+		if _, err := storage.RestoreSkill(context.Background(), testSkill.Key, "restorer"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected a purged skill to be gone, got %v", err)
+		}
+	})
+}
+
+func TestRevisionStorage(t *testing.T) {
+	forEachBackend(t, testRevisionStorage)
+}
+
+func testRevisionStorage(t *testing.T, b storageBackend) {
+	skill := Skill{Key: "rev-skill", Name: "First", Tags: []string{"a"}}
+	if err := b.storage.PostRevision(context.Background(), "event-1", "Insert", "tester", skill); err != nil {
+		t.Fatalf("PostRevision error: %v", err)
+	}
+	skill.Name = "Second"
+	if err := b.storage.PostRevision(context.Background(), "event-2", "UpdateName", "tester", skill); err != nil {
+		t.Fatalf("PostRevision error: %v", err)
+	}
+	if err := b.storage.PostRevision(context.Background(), "event-2", "Insert", "tester", Skill{Key: "other-skill"}); err != nil {
+		t.Fatalf("PostRevision error: %v", err)
+	}
+
+	revisions, err := b.revisions("rev-skill")
+	if err != nil {
+		t.Fatalf("can't read revisions: %v", err)
+	}
+	want := []revisionRow{
+		{Rev: 1, Action: "Insert", Name: "First", Tags: []string{"a"}},
+		{Rev: 2, Action: "UpdateName", Name: "Second", Tags: []string{"a"}},
+	}
+	if !reflect.DeepEqual(revisions, want) {
+		t.Errorf("Expected revisions %v, got %v", want, revisions)
+	}
+
+	revisions, err = b.revisions("other-skill")
+	if err != nil {
+		t.Fatalf("can't read revisions: %v", err)
+	}
+	if len(revisions) != 1 || revisions[0].Rev != 1 || !reflect.DeepEqual(revisions[0].Tags, []string{}) {
+		t.Errorf("Expected other-skill to start at rev 1 with no tags, got %v", revisions)
+	}
+}
@@ -0,0 +1,168 @@
+// Command dev runs the API and the consumer in one process, connected by an
+// in-process bus and backed by SQLite or memory, so the service can be tried
+// without Postgres or Kafka.
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/narunart-atise/skill-api-kafka/api/database"
+	"github.com/narunart-atise/skill-api-kafka/api/seed"
+	apiskill "github.com/narunart-atise/skill-api-kafka/api/skill"
+	"github.com/narunart-atise/skill-api-kafka/auth"
+	"github.com/narunart-atise/skill-api-kafka/config"
+	consumerskill "github.com/narunart-atise/skill-api-kafka/consumer/skill"
+	"github.com/narunart-atise/skill-api-kafka/health"
+	"github.com/narunart-atise/skill-api-kafka/idempotency"
+	"github.com/narunart-atise/skill-api-kafka/logging"
+	"github.com/narunart-atise/skill-api-kafka/memdb"
+	"github.com/narunart-atise/skill-api-kafka/metrics"
+	"github.com/narunart-atise/skill-api-kafka/ratelimit"
+	"github.com/narunart-atise/skill-api-kafka/tracing"
+	"github.com/narunart-atise/skill-api-kafka/transport"
+)
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	cfg, _, err := config.LoadDev(os.Args[1:])
+	if err != nil {
+		logging.Fatal("Invalid configuration", "error", err)
+	}
+	if err := logging.Setup(cfg.Log, "skill-dev"); err != nil {
+		logging.Fatal("Failed to set up logging", "error", err)
+	}
+	slog.Info("Effective configuration", "config", config.Redacted(cfg))
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, "skill-dev")
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	checker := health.New(cfg.Health.Timeout)
+
+	var (
+		s         apiskill.Storager
+		storage   consumerskill.Storager
+		store     ratelimit.Store   = ratelimit.NewMemory()
+		responses idempotency.Store = idempotency.NewMemory()
+	)
+	if cfg.Database.Driver == "memory" {
+		mem := memdb.New()
+		s, storage = apiskill.NewMemoryStorage(mem), consumerskill.NewMemoryStorage(mem)
+	} else {
+		db, closeDB := database.Open(ctx, cfg.Database)
+		defer closeDB()
+		if cfg.Database.StatsInterval > 0 {
+			go database.LogStats(ctx, db, cfg.Database.StatsInterval)
+		}
+		s, storage = apiskill.NewStorage(db), consumerskill.NewStorage(db)
+		if cfg.RateLimit.Store == "database" {
+			store = ratelimit.NewSQL(db)
+		}
+		if cfg.Idempotency.Store == "database" {
+			responses = idempotency.NewSQL(db)
+		}
+		checker.Add("database", health.Database(db))
+		metrics.RegisterDatabase(db, "primary")
+	}
+
+	bus := transport.NewBus(time.Second)
+	checker.Add("bus", health.Transport(bus.Subscriber(), cfg.Health.MaxLag))
+	metrics.RegisterLag(bus.Subscriber(), cfg.Health.Timeout)
+
+	consumer := consumerskill.NewConsumer(bus.Subscriber(), storage, cfg.DrainTimeout)
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		if err := consumer.Consume(ctx); err != nil {
+			slog.Error("Consumer failed", "error", err)
+		}
+	}()
+
+	producer := apiskill.NewProducer(metrics.Publisher(tracing.Publisher(logging.Publisher(bus.Publisher()))))
+	defer producer.Close()
+
+	if cfg.Fixtures != "" {
+		if err := loadFixtures(ctx, s, producer, cfg.Fixtures); err != nil {
+			logging.Fatal("Seed failed", "error", err)
+		}
+	}
+
+	limiter := ratelimit.New(cfg.RateLimit, store)
+	go limiter.Run(ctx)
+	cache := idempotency.New(cfg.Idempotency, responses)
+	go cache.Run(ctx)
+
+	r := gin.New()
+	authenticator, err := auth.New(ctx, cfg.Auth)
+	if err != nil {
+		logging.Fatal("Failed to set up authentication", "error", err)
+	}
+
+	r.Use(logging.Gin("/healthz", "/readyz", "/metrics"), gin.Recovery(), metrics.Gin(), tracing.Gin("skill-dev"))
+	apiskill.NewHandler(s, producer, cfg.CheckExistence).Routes(r.Group("", limiter.GinFailures(), auth.Gin(authenticator), limiter.Gin(), cache.Gin()))
+	r.GET("/healthz", gin.WrapF(health.Live))
+	r.GET("/readyz", gin.WrapF(checker.Ready))
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+
+	srv := http.Server{
+		Addr:    ":" + cfg.HTTP.Port,
+		Handler: r,
+	}
+
+	go func() {
+		<-ctx.Done()
+		slog.Info("Shutting down")
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Error("Shutdown failed", "error", err)
+		}
+	}()
+
+	slog.Info("Listening", "addr", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logging.Fatal("Server failed", "error", err)
+	}
+
+	<-consumed
+	slog.Info("Bye")
+}
+
+type fixtureStorage interface {
+	FindAllSkill(ctx context.Context, filter apiskill.Filter) ([]apiskill.Skill, error)
+	FindSkillByKey(ctx context.Context, key string) (apiskill.Skill, error)
+	FindDeletedSkillByKey(ctx context.Context, key string) (apiskill.Skill, error)
+}
+
+// loadFixtures publishes the fixture set through the bus the first time the
+// database is used, so the skills get revisions like any other write.
+func loadFixtures(ctx context.Context, s fixtureStorage, producer *apiskill.Producer, dir string) error {
+	existing, err := s.FindAllSkill(ctx, apiskill.Filter{})
+	if err != nil || len(existing) > 0 {
+		return err
+	}
+
+	skills, err := seed.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	_, err = seed.Publish(ctx, s, producer, skills)
+	return err
+}
@@ -1,5 +1,10 @@
 import { test, expect } from "@playwright/test";
 
+// Deleted skills keep their key, so every run creates a skill of its own.
+// The tests share it and run in order, retried together.
+const key = `js-${Date.now().toString(36)}`;
+test.describe.configure({ mode: "serial" });
+
 test("should response one skill when request /api/v1/skills/:key", async ({
   request,
 }) => {
@@ -9,13 +14,17 @@ test("should response one skill when request /api/v1/skills/:key", async ({
   expect(await reps.json()).toEqual(
     expect.objectContaining({
       status: "success",
-      data: {
+      data: expect.objectContaining({
         key: "go",
         name: "Go",
         description: expect.any(String),
         logo: expect.any(String),
         tags: expect.arrayContaining(["go", "golang"]),
-      },
+        created_at: expect.any(String),
+        updated_at: expect.any(String),
+        created_by: expect.any(String),
+        updated_by: expect.any(String),
+      }),
     })
   );
 });
@@ -25,7 +34,7 @@ test("should response error when request /api/v1/skills/:key with not exits key"
 }) => {
   const reps = await request.get("/api/v1/skills/go2");
 
-  expect(reps.status() == 500).toBeTruthy();
+  expect(reps.status()).toBe(404);
   expect(await reps.json()).toEqual(
     expect.objectContaining({
       status: "error",
@@ -38,10 +47,10 @@ test("should create a new skill when request POST /api/v1/skills", async ({
   request,
 }) => {
   const skillData = {
-    key: "js",
+    key,
     name: "JavaScript",
     description: "A versatile programming language",
-    logo: "js-logo.png",
+    logo: "https://example.com/js-logo.png",
     tags: ["js", "javascript"],
   };
 
@@ -60,14 +69,20 @@ test("should update a skill when request PUT /api/v1/skills/:key", async ({
   request,
 }) => {
   const skillData = {
-    key: "js",
+    key,
     name: "JavaScript",
     description: "A popular programming language",
-    logo: "js-logo-updated.png",
+    logo: "https://example.com/js-logo-updated.png",
     tags: ["js", "javascript", "web"],
   };
 
-  const reps = await request.put("/api/v1/skills/js", { data: skillData });
+  // The consumer stores the skill created above asynchronously; until it
+  // has, updates are refused with 404.
+  await expect
+    .poll(async () => (await request.get(`/api/v1/skills/${key}`)).status())
+    .toBe(200);
+
+  const reps = await request.put(`/api/v1/skills/${key}`, { data: skillData });
 
   expect(reps.ok()).toBeTruthy();
   expect(await reps.json()).toEqual(
@@ -83,7 +98,7 @@ test("should update skill name when request PATCH /api/v1/skills/:key/actions/na
 }) => {
   const skillNameUpdate = { name: "New JavaScript" };
 
-  const reps = await request.patch("/api/v1/skills/js/actions/name", {
+  const reps = await request.patch(`/api/v1/skills/${key}/actions/name`, {
     data: skillNameUpdate,
   });
 
@@ -92,7 +107,7 @@ test("should update skill name when request PATCH /api/v1/skills/:key/actions/na
     expect.objectContaining({
       status: "success",
       data: {
-        key: "js",
+        key,
         name: "New JavaScript",
         description: expect.any(String),
         logo: expect.any(String),
@@ -107,7 +122,7 @@ test("should update skill description when request PATCH /api/v1/skills/:key/act
 }) => {
   const skillDescriptionUpdate = { description: "Updated description" };
 
-  const reps = await request.patch("/api/v1/skills/js/actions/description", {
+  const reps = await request.patch(`/api/v1/skills/${key}/actions/description`, {
     data: skillDescriptionUpdate,
   });
 
@@ -116,7 +131,7 @@ test("should update skill description when request PATCH /api/v1/skills/:key/act
     expect.objectContaining({
       status: "success",
       data: {
-        key: "js",
+        key,
         name: expect.any(String),
         description: "Updated description",
         logo: expect.any(String),
@@ -129,9 +144,9 @@ test("should update skill description when request PATCH /api/v1/skills/:key/act
 test("should update skill logo when request PATCH /api/v1/skills/:key/actions/logo", async ({
   request,
 }) => {
-  const skillLogoUpdate = { logo: "new-logo.png" };
+  const skillLogoUpdate = { logo: "https://example.com/new-logo.png" };
 
-  const reps = await request.patch("/api/v1/skills/js/actions/logo", {
+  const reps = await request.patch(`/api/v1/skills/${key}/actions/logo`, {
     data: skillLogoUpdate,
   });
 
@@ -140,10 +155,10 @@ test("should update skill logo when request PATCH /api/v1/skills/:key/actions/lo
     expect.objectContaining({
       status: "success",
       data: {
-        key: "js",
+        key,
         name: expect.any(String),
         description: expect.any(String),
-        logo: "new-logo.png",
+        logo: "https://example.com/new-logo.png",
         tags: expect.arrayContaining(["js", "javascript"]),
       },
     })
@@ -155,7 +170,7 @@ test("should update skill tags when request PATCH /api/v1/skills/:key/actions/ta
 }) => {
   const skillTagsUpdate = { tags: ["updated", "tags"] };
 
-  const reps = await request.patch("/api/v1/skills/js/actions/tags", {
+  const reps = await request.patch(`/api/v1/skills/${key}/actions/tags`, {
     data: skillTagsUpdate,
   });
 
@@ -164,7 +179,7 @@ test("should update skill tags when request PATCH /api/v1/skills/:key/actions/ta
     expect.objectContaining({
       status: "success",
       data: {
-        key: "js",
+        key,
         name: expect.any(String),
         description: expect.any(String),
         logo: expect.any(String),
@@ -177,7 +192,7 @@ test("should update skill tags when request PATCH /api/v1/skills/:key/actions/ta
 test("should delete a skill when request DELETE /api/v1/skills/:key", async ({
   request,
 }) => {
-  const reps = await request.delete("/api/v1/skills/js");
+  const reps = await request.delete(`/api/v1/skills/${key}`);
 
   expect(reps.ok()).toBeTruthy();
   expect(await reps.json()).toEqual(
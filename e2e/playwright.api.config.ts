@@ -6,9 +6,10 @@ export default defineConfig({
     extraHTTPHeaders: {
       // We set this header per GitHub guidelines.
       Accept: "application/json",
-      // Add authorization token to all requests.
-      // Assuming personal access token available in the environment.
-      Authorization: `token ${process.env.API_TOKEN}`,
+      // Authenticate with an API key the API under test accepts. The default
+      // is the admin key api.config.yaml sets up, see "End-to-end tests" in
+      // the README.
+      "X-API-Key": process.env.API_KEY ?? "e2e-admin-key",
     },
   },
 });
@@ -0,0 +1,174 @@
+// Package health serves the liveness and readiness probes of the API and the
+// consumer. Liveness only tells that the process is serving; readiness runs
+// one check per dependency and reports each of them as JSON.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/transport"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check probes one dependency. It returns details worth reporting, and an
+// error when the dependency keeps the service from working.
+type Check func(ctx context.Context) (detail any, err error)
+
+type Result struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Detail any    `json:"detail,omitempty"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs the readiness checks.
+type Checker struct {
+	timeout time.Duration
+
+	mu     sync.Mutex
+	checks map[string]Check
+}
+
+// New returns a Checker giving each check timeout to answer.
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: map[string]Check{}}
+}
+
+// Add registers check under name, replacing any check with the same name.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Run runs every check concurrently. The report fails if any check does.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.Lock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	report := Report{Status: StatusOK, Checks: map[string]Result{}}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// run calls check, giving up when ctx expires even if check ignores it.
+func run(ctx context.Context, check Check) Result {
+	type answer struct {
+		detail any
+		err    error
+	}
+	done := make(chan answer, 1)
+	go func() {
+		detail, err := check(ctx)
+		done <- answer{detail, err}
+	}()
+
+	var a answer
+	select {
+	case a = <-done:
+	case <-ctx.Done():
+		a.err = fmt.Errorf("no answer: %w", ctx.Err())
+	}
+
+	if a.err != nil {
+		return Result{Status: StatusFail, Error: a.err.Error(), Detail: a.detail}
+	}
+	return Result{Status: StatusOK, Detail: a.detail}
+}
+
+// Ready answers readiness probes with the report, as 503 when a check fails.
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// Live answers liveness probes. A process able to answer is alive.
+func Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Report{Status: StatusOK, Checks: map[string]Result{}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Database checks that db answers a ping and reports its pool usage.
+func Database(db *sql.DB) Check {
+	return func(ctx context.Context) (any, error) {
+		stats := db.Stats()
+		detail := map[string]int{
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+			"idle":             stats.Idle,
+		}
+		return detail, db.PingContext(ctx)
+	}
+}
+
+// Transport checks a publisher or subscriber implementing transport.Checker
+// and passes for those that don't. With maxLag above 0, a subscriber with
+// more messages waiting than that fails.
+func Transport(client any, maxLag int64) Check {
+	checker, ok := client.(transport.Checker)
+	if !ok {
+		return func(context.Context) (any, error) { return nil, nil }
+	}
+
+	return func(ctx context.Context) (any, error) {
+		status, err := checker.Check(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if maxLag > 0 && status.Lag != nil && *status.Lag > maxLag {
+			return status, fmt.Errorf("lag of %d messages exceeds %d", *status.Lag, maxLag)
+		}
+		return status, nil
+	}
+}
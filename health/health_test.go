@@ -0,0 +1,113 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/transport"
+)
+
+func ready(t *testing.T, c *Checker) (int, Report) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	c.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Expected a JSON report, got %s", rec.Body)
+	}
+	return rec.Code, report
+}
+
+func TestReady(t *testing.T) {
+	c := New(time.Second)
+	c.Add("database", func(context.Context) (any, error) { return map[string]int{"idle": 1}, nil })
+	c.Add("kafka", func(context.Context) (any, error) { return nil, nil })
+
+	code, report := ready(t, c)
+	if code != http.StatusOK || report.Status != StatusOK {
+		t.Errorf("Expected 200 ok, got %d %s", code, report.Status)
+	}
+	if len(report.Checks) != 2 || report.Checks["database"].Detail == nil {
+		t.Errorf("Expected a result with detail per check, got %+v", report.Checks)
+	}
+
+	c.Add("kafka", func(context.Context) (any, error) { return nil, errors.New("no broker") })
+	code, report = ready(t, c)
+	if code != http.StatusServiceUnavailable || report.Status != StatusFail {
+		t.Errorf("Expected 503 fail, got %d %s", code, report.Status)
+	}
+	if got := report.Checks["kafka"]; got.Status != StatusFail || got.Error != "no broker" {
+		t.Errorf("Expected the failing check to be reported, got %+v", got)
+	}
+	if got := report.Checks["database"]; got.Status != StatusOK {
+		t.Errorf("Expected the other check to pass, got %+v", got)
+	}
+}
+
+func TestReadyTimeout(t *testing.T) {
+	c := New(20 * time.Millisecond)
+	block := make(chan struct{})
+	defer close(block)
+	c.Add("stuck", func(context.Context) (any, error) {
+		<-block
+		return nil, nil
+	})
+
+	code, report := ready(t, c)
+	if code != http.StatusServiceUnavailable || !strings.Contains(report.Checks["stuck"].Error, "deadline") {
+		t.Errorf("Expected a check ignoring its context to time out, got %d %+v", code, report.Checks)
+	}
+}
+
+func TestLive(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Live(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ok"`) {
+		t.Errorf("Expected 200 ok, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+type fakeSubscriber struct {
+	status transport.Status
+	err    error
+}
+
+func (f fakeSubscriber) Check(context.Context) (transport.Status, error) {
+	return f.status, f.err
+}
+
+func TestTransport(t *testing.T) {
+	lag := int64(10)
+	sub := fakeSubscriber{status: transport.Status{Partitions: []int32{0, 1}, Lag: &lag}}
+
+	if _, err := Transport(sub, 0)(context.Background()); err != nil {
+		t.Errorf("Expected no lag limit by default, got %v", err)
+	}
+	if _, err := Transport(sub, 10)(context.Background()); err != nil {
+		t.Errorf("Expected a lag at the limit to pass, got %v", err)
+	}
+	detail, err := Transport(sub, 9)(context.Background())
+	if err == nil {
+		t.Error("Expected a lag above the limit to fail")
+	}
+	if status, ok := detail.(transport.Status); !ok || len(status.Partitions) != 2 {
+		t.Errorf("Expected the status as detail, got %+v", detail)
+	}
+
+	sub.err = errors.New("no partitions assigned")
+	if _, err := Transport(sub, 0)(context.Background()); err == nil {
+		t.Error("Expected the subscriber error")
+	}
+
+	if _, err := Transport(struct{}{}, 1)(context.Background()); err != nil {
+		t.Errorf("Expected clients without checks to pass, got %v", err)
+	}
+}
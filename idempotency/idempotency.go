@@ -0,0 +1,197 @@
+// Package idempotency replays the response of a write request retried with
+// the same Idempotency-Key header, so a client retrying after a timeout does
+// not publish its command twice. Responses are kept in memory, per replica,
+// or in the database shared by every replica.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/narunart-atise/skill-api-kafka/auth"
+	"github.com/narunart-atise/skill-api-kafka/config"
+)
+
+const (
+	// Header carries the key a client picks for a request and sends again
+	// with each retry of it.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on replayed responses.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+)
+
+// Response is what a store records for a key.
+type Response struct {
+	// Fingerprint identifies the request the key was first sent with.
+	Fingerprint string
+	// Status is 0 while that request is being handled.
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Store keeps the keys and their responses.
+type Store interface {
+	// Reserve records key for the request with fingerprint until expires,
+	// unless key is recorded already and has not expired at now. It then
+	// returns what was recorded instead.
+	Reserve(ctx context.Context, key, fingerprint string, now, expires time.Time) (Response, bool, error)
+	// Save records the response to the request key was reserved for.
+	Save(ctx context.Context, key string, r Response) error
+	// Release forgets key, so its request may be tried again.
+	Release(ctx context.Context, key string) error
+	// Prune forgets the keys expired at now.
+	Prune(ctx context.Context, now time.Time) error
+}
+
+// Cache replays the responses of write requests as configured.
+type Cache struct {
+	store Store
+	ttl   time.Duration
+}
+
+// New returns a cache keeping its responses in store.
+func New(cfg config.Idempotency, store Store) *Cache {
+	return &Cache{store: store, ttl: cfg.TTL}
+}
+
+// Run forgets expired keys every hour, or every TTL when shorter, until ctx
+// is cancelled.
+func (c *Cache) Run(ctx context.Context) {
+	ticker := time.NewTicker(min(c.ttl, time.Hour))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if err := c.store.Prune(ctx, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "Pruning idempotency keys failed", "error", err)
+		}
+	}
+}
+
+// Gin handles write requests carrying an Idempotency-Key header once per key
+// and client. A retry gets the recorded response back, with the
+// Idempotent-Replayed header; a retry while the first request is still being
+// handled gets 409, and the key sent with another request gets 422.
+//
+// Server errors are not recorded, so the request may be retried. Neither are
+// requests made while the store fails, which are handled as if they had no
+// key rather than refused.
+func (c *Cache) Gin() gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		key := gc.GetHeader(Header)
+		if key == "" || gc.Request.Method == http.MethodGet || gc.Request.Method == http.MethodHead {
+			gc.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			gc.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		body, err := io.ReadAll(gc.Request.Body)
+		if err != nil {
+			gc.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "error", "message": "can't read request body"})
+			return
+		}
+		gc.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := gc.Request.Context()
+		// Keys are per client, so clients cannot replay each other's
+		// responses. Newlines cannot appear in a header value.
+		key = auth.Client(gc) + "\n" + key
+		fingerprint := fingerprint(gc.Request, body)
+		now := time.Now()
+		recorded, reserved, err := c.store.Reserve(ctx, key, fingerprint, now, now.Add(c.ttl))
+		if err != nil {
+			slog.ErrorContext(ctx, "Idempotency key lookup failed, handling the request without it", "error", err)
+			gc.Next()
+			return
+		}
+		if !reserved {
+			switch {
+			case recorded.Fingerprint != fingerprint:
+				gc.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"status": "error", "message": "Idempotency-Key was already used for another request"})
+			case recorded.Status == 0:
+				gc.AbortWithStatusJSON(http.StatusConflict, gin.H{"status": "error", "message": "a request with this Idempotency-Key is in progress"})
+			default:
+				slog.DebugContext(ctx, "Replaying response", "status", recorded.Status)
+				gc.Header(ReplayedHeader, "true")
+				if recorded.ContentType != "" {
+					gc.Header("Content-Type", recorded.ContentType)
+				}
+				gc.Status(recorded.Status)
+				gc.Writer.Write(recorded.Body)
+				gc.Abort()
+			}
+			return
+		}
+
+		// The response is recorded even when the client went away, as it may
+		// well retry.
+		ctx = context.WithoutCancel(ctx)
+		defer func() {
+			// A panic is answered with 500 by gin.Recovery further up.
+			if p := recover(); p != nil {
+				c.store.Release(ctx, key)
+				panic(p)
+			}
+		}()
+
+		w := &recorder{ResponseWriter: gc.Writer}
+		gc.Writer = w
+		gc.Next()
+
+		if status := w.Status(); status >= http.StatusInternalServerError {
+			err = c.store.Release(ctx, key)
+		} else {
+			err = c.store.Save(ctx, key, Response{
+				Fingerprint: fingerprint,
+				Status:      status,
+				ContentType: w.Header().Get("Content-Type"),
+				Body:        w.body.Bytes(),
+			})
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Recording idempotent response failed", "error", err)
+		}
+	}
+}
+
+// fingerprint tells apart requests sent with the same key.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder keeps a copy of the response body.
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,144 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/narunart-atise/skill-api-kafka/auth"
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/narunart-atise/skill-api-kafka/migrations"
+	_ "modernc.org/sqlite"
+)
+
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(migrations.SQLiteSchema); err != nil {
+		t.Fatalf("can't create tables: %v", err)
+	}
+	return map[string]Store{"memory": NewMemory(), "sql": NewSQL(db)}
+}
+
+func TestStores(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	expires := now.Add(time.Hour)
+
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, ok, err := s.Reserve(ctx, "alice\nk1", "fp", now, expires); err != nil || !ok {
+				t.Fatalf("Expected the key to be reserved, got %v, %v", ok, err)
+			}
+			if r, ok, err := s.Reserve(ctx, "alice\nk1", "other", now, expires); err != nil || ok || r.Fingerprint != "fp" || r.Status != 0 {
+				t.Errorf("Expected the key in progress, got %+v, %v, %v", r, ok, err)
+			}
+
+			saved := Response{Fingerprint: "fp", Status: http.StatusOK, ContentType: "application/json", Body: []byte(`{"status":"success"}`)}
+			if err := s.Save(ctx, "alice\nk1", saved); err != nil {
+				t.Fatal(err)
+			}
+			r, ok, err := s.Reserve(ctx, "alice\nk1", "fp", now.Add(time.Minute), expires)
+			if err != nil || ok || r.Status != saved.Status || r.ContentType != saved.ContentType || string(r.Body) != string(saved.Body) {
+				t.Errorf("Expected the saved response, got %+v, %v, %v", r, ok, err)
+			}
+			if _, ok, _ := s.Reserve(ctx, "alice\nk1", "fp", expires, expires.Add(time.Hour)); !ok {
+				t.Error("Expected an expired key to be reserved again")
+			}
+
+			if err := s.Release(ctx, "alice\nk1"); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, _ := s.Reserve(ctx, "alice\nk1", "fp", now, expires); !ok {
+				t.Error("Expected a released key to be reserved again")
+			}
+
+			if err := s.Prune(ctx, expires); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, _ := s.Reserve(ctx, "alice\nk1", "other", now, expires); !ok {
+				t.Error("Expected a pruned key to be forgotten")
+			}
+		})
+	}
+}
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var (
+		handled int
+		status  = http.StatusOK
+		entered chan struct{}
+	)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), auth.Principal{Subject: c.GetHeader("X-Principal"), Method: "jwt"}))
+	}, New(config.Idempotency{TTL: time.Hour}, NewMemory()).Gin())
+	r.POST("/api/v1/skills", func(c *gin.Context) {
+		if entered != nil {
+			// Hold the key until the test has retried.
+			entered <- struct{}{}
+			<-entered
+		}
+		handled++
+		c.JSON(status, gin.H{"status": "success", "handled": handled})
+	})
+
+	do := func(principal, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/skills", strings.NewReader(body))
+		req.Header.Set("X-Principal", principal)
+		if key != "" {
+			req.Header.Set(Header, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := do("alice", "k1", `{"key":"go"}`)
+	retry := do("alice", "k1", `{"key":"go"}`)
+	if handled != 1 || retry.Code != first.Code || retry.Body.String() != first.Body.String() || retry.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("Expected the retry to replay %d %s, got %d %s %v after %d handled", first.Code, first.Body, retry.Code, retry.Body, retry.Header(), handled)
+	}
+	if w := do("alice", "k1", `{"key":"rust"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected the key reused for another request to be refused, got %d", w.Code)
+	}
+	if do("bob", "k1", `{"key":"go"}`); handled != 2 {
+		t.Errorf("Expected keys to be per client, got %d handled", handled)
+	}
+	if do("alice", "", `{"key":"go"}`); handled != 3 {
+		t.Errorf("Expected requests without a key to be handled, got %d handled", handled)
+	}
+
+	status = http.StatusInternalServerError
+	do("alice", "k2", `{}`)
+	status = http.StatusOK
+	if w := do("alice", "k2", `{}`); w.Code != http.StatusOK || handled != 5 {
+		t.Errorf("Expected a failed request to be tried again, got %d after %d handled", w.Code, handled)
+	}
+
+	entered = make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		do("alice", "k3", `{}`)
+	}()
+	<-entered
+	if w := do("alice", "k3", `{}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected a retry during the first request to conflict, got %d", w.Code)
+	}
+	entered <- struct{}{}
+	<-done
+}
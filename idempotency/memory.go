@@ -0,0 +1,64 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps the responses of a single replica.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+type entry struct {
+	response Response
+	expires  time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: map[string]entry{}}
+}
+
+func (m *Memory) Reserve(_ context.Context, key, fingerprint string, now, expires time.Time) (Response, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok && e.expires.After(now) {
+		return e.response, false, nil
+	}
+	m.entries[key] = entry{response: Response{Fingerprint: fingerprint}, expires: expires}
+	return Response{}, true, nil
+}
+
+func (m *Memory) Save(_ context.Context, key string, r Response) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		e.response = r
+		m.entries[key] = e
+	}
+	return nil
+}
+
+func (m *Memory) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *Memory) Prune(_ context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, e := range m.entries {
+		if !e.expires.After(now) {
+			delete(m.entries, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// reserveQuery records a key, or takes over an expired one not pruned yet,
+// in one statement so replicas racing for the same key cannot both get it.
+// Times are Unix nanoseconds.
+const reserveQuery = `INSERT INTO idempotency_key (key, fingerprint, expires_at) VALUES ($1, $2, $3)
+ON CONFLICT (key) DO UPDATE SET fingerprint=EXCLUDED.fingerprint, status=0, content_type='', body='', expires_at=EXCLUDED.expires_at
+WHERE idempotency_key.expires_at <= $4`
+
+// SQL keeps the responses in the idempotency_key table of a Postgres or
+// SQLite database, shared by the replicas using it.
+type SQL struct {
+	db *sql.DB
+}
+
+func NewSQL(db *sql.DB) *SQL {
+	return &SQL{db: db}
+}
+
+func (s *SQL) Reserve(ctx context.Context, key, fingerprint string, now, expires time.Time) (Response, bool, error) {
+	res, err := s.db.ExecContext(ctx, reserveQuery, key, fingerprint, expires.UnixNano(), now.UnixNano())
+	if err != nil {
+		return Response{}, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return Response{}, false, err
+	}
+	if n == 1 {
+		return Response{}, true, nil
+	}
+
+	var (
+		r    Response
+		body string
+	)
+	q := "SELECT fingerprint, status, content_type, body FROM idempotency_key WHERE key=$1"
+	if err := s.db.QueryRowContext(ctx, q, key).Scan(&r.Fingerprint, &r.Status, &r.ContentType, &body); err != nil {
+		return Response{}, false, err
+	}
+	r.Body = []byte(body)
+	return r, false, nil
+}
+
+func (s *SQL) Save(ctx context.Context, key string, r Response) error {
+	q := "UPDATE idempotency_key SET status=$2, content_type=$3, body=$4 WHERE key=$1"
+	_, err := s.db.ExecContext(ctx, q, key, r.Status, r.ContentType, string(r.Body))
+	return err
+}
+
+func (s *SQL) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_key WHERE key=$1", key)
+	return err
+}
+
+func (s *SQL) Prune(ctx context.Context, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_key WHERE expires_at <= $1", now.UnixNano())
+	return err
+}
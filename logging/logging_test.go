@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/narunart-atise/skill-api-kafka/transport"
+)
+
+func capture(t *testing.T, cfg config.Log) *bytes.Buffer {
+	t.Helper()
+
+	var out bytes.Buffer
+	logger, err := New(&out, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &out
+}
+
+func TestRequestID(t *testing.T) {
+	out := capture(t, config.Log{Level: "debug", Format: "json"})
+	gin.SetMode(gin.TestMode)
+
+	var seen string
+	r := gin.New()
+	r.Use(Gin("/healthz"))
+	r.GET("/skills", func(c *gin.Context) { seen = RequestID(c.Request.Context()) })
+
+	for _, tc := range []struct {
+		header string
+		keep   bool
+	}{
+		{"abc-123", true},
+		{"", false},
+		{"two words\n", false},
+	} {
+		out.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/skills", nil)
+		if tc.header != "" {
+			req.Header.Set(RequestIDHeader, tc.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		echoed := w.Header().Get(RequestIDHeader)
+		if echoed == "" || echoed != seen || (echoed == tc.header) != tc.keep {
+			t.Errorf("%q: expected the handler and the response to share a usable ID, got %q and %q", tc.header, seen, echoed)
+		}
+
+		var line map[string]any
+		if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+			t.Fatalf("Expected one JSON line, got %s", out)
+		}
+		if line["request_id"] != echoed || line["route"] != "/skills" || line["level"] != "INFO" {
+			t.Errorf("Expected the request logged with its ID, got %v", line)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	out := capture(t, config.Log{Level: "info", Format: "text"})
+
+	slog.Debug("hidden")
+	slog.InfoContext(WithRequestID(context.Background(), "abc"), "shown")
+
+	if strings.Contains(out.String(), "hidden") || !strings.Contains(out.String(), "msg=shown request_id=abc") {
+		t.Errorf("Expected only the info line with its request ID, got %s", out)
+	}
+
+	if _, err := New(out, config.Log{Level: "trace"}); err == nil {
+		t.Error("Expected an unknown level to be refused")
+	}
+}
+
+func TestPayload(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		payloads, redact = "", nil
+	})
+
+	value := []byte(`{"action":"create","actor":"alice","data":{"key":"go","tags":[{"actor":"bob"}]}}`)
+
+	for _, tc := range []struct {
+		mode string
+		want string
+	}{
+		{"none", ``},
+		{"full", `{"action":"create","actor":"alice","data":{"key":"go","tags":[{"actor":"bob"}]}}`},
+		{"redacted", `{"action":"create","actor":"******","data":{"key":"go","tags":[{"actor":"******"}]}}`},
+	} {
+		cfg := config.Log{Level: "info", Format: "json", Payloads: tc.mode, Redact: []string{"actor"}}
+		if err := Setup(cfg, "test"); err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		logger, _ := New(&out, cfg)
+		slog.SetDefault(logger)
+
+		slog.Info("consumed", Payload(value))
+
+		var line struct {
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		if string(line.Payload) != tc.want {
+			t.Errorf("%s: expected payload %s, got %s", tc.mode, tc.want, line.Payload)
+		}
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	bus := transport.NewBus(time.Millisecond)
+
+	ctx := WithRequestID(context.Background(), "abc")
+	msg := transport.Message{Key: "go", Headers: map[string]string{"other": "kept"}}
+	if err := Publisher(bus.Publisher()).Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Headers[RequestIDHeader] != "" {
+		t.Error("Expected the caller's headers to be left alone")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got string
+	handle := Handler(func(ctx context.Context, msg transport.Message) error {
+		got = RequestID(ctx)
+		cancel()
+		return nil
+	})
+	if err := bus.Subscriber().Subscribe(ctx, handle); err != nil {
+		t.Fatal(err)
+	}
+	if got != "abc" {
+		t.Errorf("Expected the handler to see the request ID, got %q", got)
+	}
+}
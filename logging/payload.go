@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+)
+
+const masked = "******"
+
+// Set by Setup; until then no payload is logged.
+var (
+	payloads string
+	redact   map[string]bool
+)
+
+// Payload returns the attribute logging a message payload as configured:
+// nothing, the payload with the redacted fields masked at any depth, or the
+// full payload. A payload that is not JSON is only logged in full.
+func Payload(value []byte) slog.Attr {
+	switch payloads {
+	case "full":
+		if !json.Valid(value) {
+			return slog.String("payload", string(value))
+		}
+		return slog.Any("payload", rawJSON(value))
+	case "redacted":
+		decoder := json.NewDecoder(bytes.NewReader(value))
+		decoder.UseNumber()
+		var v any
+		if err := decoder.Decode(&v); err != nil {
+			return slog.Int("payload_bytes", len(value))
+		}
+		redacted, err := json.Marshal(redactValue(v))
+		if err != nil {
+			return slog.Int("payload_bytes", len(value))
+		}
+		return slog.Any("payload", rawJSON(redacted))
+	default:
+		return slog.Attr{}
+	}
+}
+
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if redact[key] {
+				v[key] = masked
+			} else {
+				v[key] = redactValue(value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactValue(value)
+		}
+	}
+	return v
+}
+
+// rawJSON is embedded as is by the JSON handler and as a string by the text
+// handler.
+type rawJSON []byte
+
+func (r rawJSON) MarshalJSON() ([]byte, error) { return r, nil }
+
+func (r rawJSON) MarshalText() ([]byte, error) { return r, nil }
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/narunart-atise/skill-api-kafka/transport"
+)
+
+type publisher struct {
+	transport.Publisher
+}
+
+// Publisher adds the request ID carried by the context to the headers of
+// each message published through p.
+func Publisher(p transport.Publisher) transport.Publisher {
+	return publisher{p}
+}
+
+func (p publisher) Publish(ctx context.Context, msg transport.Message) error {
+	if id := RequestID(ctx); id != "" {
+		// The caller's headers are copied rather than written to.
+		headers := make(map[string]string, len(msg.Headers)+1)
+		for name, value := range msg.Headers {
+			headers[name] = value
+		}
+		headers[RequestIDHeader] = id
+		msg.Headers = headers
+	}
+	return p.Publisher.Publish(ctx, msg)
+}
+
+// Handler hands each message to handle with a context carrying the request
+// ID found in its headers.
+func Handler(handle transport.Handler) transport.Handler {
+	return func(ctx context.Context, msg transport.Message) error {
+		if id := msg.Headers[RequestIDHeader]; id != "" {
+			ctx = WithRequestID(ctx, id)
+		}
+		return handle(ctx, msg)
+	}
+}
@@ -0,0 +1,176 @@
+// Package memdb is an in-memory stand-in for the skill and skill_revision
+// tables. The API and the consumer each wrap it in their own storage, so in
+// dev mode both can share one DB without any database server.
+package memdb
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Skill is a row of the skill table.
+type Skill struct {
+	Key         string
+	Name        string
+	Description string
+	Logo        string
+	Tags        []string
+	DeletedAt   *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CreatedBy   string
+	UpdatedBy   string
+}
+
+// Revision is a row of the skill_revision table.
+type Revision struct {
+	Key         string
+	Rev         int
+	EventID     string
+	Action      string
+	Actor       string
+	Name        string
+	Description string
+	Logo        string
+	Tags        []string
+	CreatedAt   time.Time
+}
+
+type DB struct {
+	mu        sync.RWMutex
+	skills    map[string]Skill
+	revisions map[string][]Revision
+}
+
+func New() *DB {
+	return &DB{skills: map[string]Skill{}, revisions: map[string][]Revision{}}
+}
+
+// Tx reads and writes the tables while the DB is locked. Rows going in and
+// out are copied, so callers never share slices with the DB.
+type Tx struct {
+	db *DB
+}
+
+// View runs fn with a read lock held. fn must not write.
+func (db *DB) View(fn func(tx Tx) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return fn(Tx{db})
+}
+
+// Update runs fn with the write lock held.
+func (db *DB) Update(fn func(tx Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return fn(Tx{db})
+}
+
+// Skill returns the row with the given key, deleted or not.
+func (tx Tx) Skill(key string) (Skill, bool) {
+	s, ok := tx.db.skills[key]
+	return s.clone(), ok
+}
+
+// Skills returns every row ordered by key.
+func (tx Tx) Skills() []Skill {
+	skills := make([]Skill, 0, len(tx.db.skills))
+	for _, s := range tx.db.skills {
+		skills = append(skills, s.clone())
+	}
+	sort.Slice(skills, func(i, j int) bool { return skills[i].Key < skills[j].Key })
+	return skills
+}
+
+// PutSkill inserts s or replaces the row with the same key.
+func (tx Tx) PutSkill(s Skill) {
+	tx.db.skills[s.Key] = s.clone()
+}
+
+// InsertSkill adds s as a live row created and updated by actor now. Like
+// the primary key, it refuses a key that is taken, even by a deleted row.
+func (tx Tx) InsertSkill(s Skill, actor string) error {
+	if _, ok := tx.db.skills[s.Key]; ok {
+		return fmt.Errorf("skill %q already exists", s.Key)
+	}
+	now := time.Now().UTC()
+	s.DeletedAt = nil
+	s.CreatedAt, s.UpdatedAt = now, now
+	s.CreatedBy, s.UpdatedBy = actor, actor
+	tx.PutSkill(s)
+	return nil
+}
+
+// EditSkill applies change to the row with the given key and stamps it as
+// updated by actor now. Deleted rows are left alone unless includeDeleted is
+// set. It reports whether a row was changed.
+func (tx Tx) EditSkill(key, actor string, includeDeleted bool, change func(row *Skill)) bool {
+	row, ok := tx.Skill(key)
+	if !ok || row.DeletedAt != nil && !includeDeleted {
+		return false
+	}
+	change(&row)
+	row.UpdatedAt = time.Now().UTC()
+	row.UpdatedBy = actor
+	tx.PutSkill(row)
+	return true
+}
+
+func (tx Tx) DeleteSkill(key string) {
+	delete(tx.db.skills, key)
+}
+
+// Revisions returns the revisions of key ordered by rev.
+func (tx Tx) Revisions(key string) []Revision {
+	revisions := make([]Revision, 0, len(tx.db.revisions[key]))
+	for _, r := range tx.db.revisions[key] {
+		revisions = append(revisions, r.clone())
+	}
+	return revisions
+}
+
+// AllRevisions returns every revision ordered by key and rev.
+func (tx Tx) AllRevisions() []Revision {
+	keys := make([]string, 0, len(tx.db.revisions))
+	for key := range tx.db.revisions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var revisions []Revision
+	for _, key := range keys {
+		revisions = append(revisions, tx.Revisions(key)...)
+	}
+	return revisions
+}
+
+// AddRevision appends r to the history of r.Key, numbering it after the
+// latest revision, and returns it with Rev set.
+func (tx Tx) AddRevision(r Revision) Revision {
+	r = r.clone()
+	r.Rev = len(tx.db.revisions[r.Key]) + 1
+	tx.db.revisions[r.Key] = append(tx.db.revisions[r.Key], r)
+	return r.clone()
+}
+
+func (s Skill) clone() Skill {
+	s.Tags = cloneTags(s.Tags)
+	if s.DeletedAt != nil {
+		deletedAt := *s.DeletedAt
+		s.DeletedAt = &deletedAt
+	}
+	return s
+}
+
+func (r Revision) clone() Revision {
+	r.Tags = cloneTags(r.Tags)
+	return r
+}
+
+// cloneTags copies tags, turning nil into an empty slice the way a NOT NULL
+// DEFAULT '{}' column reads back.
+func cloneTags(tags []string) []string {
+	return append([]string{}, tags...)
+}
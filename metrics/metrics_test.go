@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/narunart-atise/skill-api-kafka/transport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Gin())
+	r.GET("/skills/:key", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	for _, path := range []string{"/skills/go", "/skills/rust", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/skills/:key", "404")); got != 2 {
+		t.Errorf("Expected 2 requests on the route pattern, got %v", got)
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", unmatched, "404")); got != 1 {
+		t.Errorf("Expected 1 unmatched request, got %v", got)
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	h := Instrument("/readyz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/readyz", "503")); got != 1 {
+		t.Errorf("Expected the status written by the handler, got %v", got)
+	}
+}
+
+type failingPublisher struct {
+	inFlight float64
+}
+
+func (p *failingPublisher) Publish(context.Context, transport.Message) error {
+	p.inFlight = testutil.ToFloat64(sendInFlight)
+	return errors.New("broker down")
+}
+
+func (p *failingPublisher) Close() error { return nil }
+
+func TestPublisher(t *testing.T) {
+	inner := &failingPublisher{}
+	errorsBefore := testutil.ToFloat64(sendErrors)
+
+	if err := Publisher(inner).Publish(context.Background(), transport.Message{}); err == nil {
+		t.Fatal("Expected the publisher error")
+	}
+
+	if inner.inFlight != 1 || testutil.ToFloat64(sendInFlight) != 0 {
+		t.Errorf("Expected 1 message in flight while publishing and none after, got %v", inner.inFlight)
+	}
+	if got := testutil.ToFloat64(sendErrors) - errorsBefore; got != 1 {
+		t.Errorf("Expected 1 send error, got %v", got)
+	}
+}
+
+type fakeSubscriber struct {
+	status transport.Status
+	err    error
+}
+
+func (f fakeSubscriber) Check(context.Context) (transport.Status, error) {
+	return f.status, f.err
+}
+
+func TestLagCollector(t *testing.T) {
+	lag := int64(7)
+	c := lagCollector{checker: fakeSubscriber{status: transport.Status{
+		Lag:          &lag,
+		PartitionLag: map[int32]int64{0: 3, 1: 4},
+	}}, timeout: time.Second}
+
+	want := `
+# HELP skill_consumer_lag Messages waiting to be consumed, by partition. Backends without partitions report an empty partition.
+# TYPE skill_consumer_lag gauge
+skill_consumer_lag{partition="0"} 3
+skill_consumer_lag{partition="1"} 4
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	c.checker = fakeSubscriber{err: errors.New("rebalancing")}
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Errorf("Expected no lag while it is unknown, got %d series", n)
+	}
+}
+
+func TestSaramaCollector(t *testing.T) {
+	r := gometrics.NewRegistry()
+	gometrics.GetOrRegisterMeter("request-rate", r).Mark(3)
+	gometrics.GetOrRegisterMeter("request-rate-for-broker-1", r).Mark(2)
+	gometrics.GetOrRegisterCounter("requests-in-flight", r).Inc(1)
+	h := gometrics.GetOrRegisterHistogram("batch-size-for-topic-skill", r, gometrics.NewUniformSample(10))
+	h.Update(100)
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(saramaCollector{client: "producer", registry: r})
+
+	want := `
+# HELP sarama_request_total Sarama metric request-rate.
+# TYPE sarama_request_total counter
+sarama_request_total{broker="",client="producer",topic=""} 3
+sarama_request_total{broker="1",client="producer",topic=""} 2
+# HELP sarama_requests_in_flight Sarama metric requests-in-flight.
+# TYPE sarama_requests_in_flight gauge
+sarama_requests_in_flight{broker="",client="producer",topic=""} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "sarama_request_total", "sarama_requests_in_flight"); err != nil {
+		t.Error(err)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, family := range families {
+		if family.GetName() == "sarama_batch_size" {
+			found = family.GetMetric()[0].GetSummary().GetSampleCount() == 1
+		}
+	}
+	if !found {
+		t.Error("Expected the histogram as a summary with one sample")
+	}
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/narunart-atise/skill-api-kafka/transport"
+	"github.com/prometheus/client_golang/prometheus"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// saramaScope splits the broker or topic sarama appends to per-broker and
+// per-topic metric names, e.g. request-rate-for-broker-1, into a label.
+var saramaScope = regexp.MustCompile(`^(.+)-for-(broker|topic)-(.+)$`)
+
+var saramaName = strings.NewReplacer("-", "_", ".", "_")
+
+var saramaQuantiles = []float64{0.5, 0.75, 0.95, 0.99}
+
+// RegisterSarama exports the go-metrics registry of a publisher or subscriber
+// implementing transport.Instrumented as sarama_* metrics labelled with
+// client. Others are ignored.
+func RegisterSarama(client any, name string) {
+	if instrumented, ok := client.(transport.Instrumented); ok {
+		registry.MustRegister(saramaCollector{client: name, registry: instrumented.MetricRegistry()})
+	}
+}
+
+// saramaCollector converts the registry on every scrape: meters become
+// counters of their events, histograms summaries, and counters and gauges
+// gauges, as sarama counts things like requests in flight with counters.
+// Sarama registers metrics as brokers and topics show up, so the collector
+// is unchecked and describes nothing upfront.
+type saramaCollector struct {
+	client   string
+	registry gometrics.Registry
+}
+
+func (c saramaCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c saramaCollector) Collect(ch chan<- prometheus.Metric) {
+	c.registry.Each(func(name string, metric any) {
+		broker, topic := "", ""
+		if m := saramaScope.FindStringSubmatch(name); m != nil {
+			name = m[1]
+			if m[2] == "broker" {
+				broker = m[3]
+			} else {
+				topic = m[3]
+			}
+		}
+		fqName := "sarama_" + saramaName.Replace(name)
+		labels := []string{c.client, broker, topic}
+
+		switch m := metric.(type) {
+		case gometrics.Meter:
+			desc := saramaDesc(strings.TrimSuffix(fqName, "_rate")+"_total", name)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(m.Count()), labels...)
+		case gometrics.Histogram:
+			s := m.Snapshot()
+			quantiles := map[float64]float64{}
+			for i, value := range s.Percentiles(saramaQuantiles) {
+				quantiles[saramaQuantiles[i]] = value
+			}
+			ch <- prometheus.MustNewConstSummary(saramaDesc(fqName, name), uint64(s.Count()), float64(s.Sum()), quantiles, labels...)
+		case gometrics.Counter:
+			ch <- prometheus.MustNewConstMetric(saramaDesc(fqName, name), prometheus.GaugeValue, float64(m.Count()), labels...)
+		case gometrics.Gauge:
+			ch <- prometheus.MustNewConstMetric(saramaDesc(fqName, name), prometheus.GaugeValue, float64(m.Value()), labels...)
+		case gometrics.GaugeFloat64:
+			ch <- prometheus.MustNewConstMetric(saramaDesc(fqName, name), prometheus.GaugeValue, m.Value(), labels...)
+		}
+	})
+}
+
+func saramaDesc(fqName, name string) *prometheus.Desc {
+	return prometheus.NewDesc(fqName, "Sarama metric "+name+".", []string{"client", "broker", "topic"}, nil)
+}
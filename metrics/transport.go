@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/transport"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	sendDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "producer",
+		Name:      "send_duration_seconds",
+		Help:      "Time taken to publish a message, failed attempts included.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	sendErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "producer",
+		Name:      "send_errors_total",
+		Help:      "Messages that could not be published.",
+	})
+
+	sendInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "producer",
+		Name:      "in_flight_messages",
+		Help:      "Messages being published and not yet acknowledged by the broker.",
+	})
+
+	messages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_total",
+		Help:      "Messages consumed, by action and outcome.",
+	}, []string{"action", "outcome"})
+
+	handleDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "handler_duration_seconds",
+		Help:      "Time taken to handle a message, by action.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"action"})
+
+	lagDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "consumer", "lag"),
+		"Messages waiting to be consumed, by partition. Backends without partitions report an empty partition.",
+		[]string{"partition"}, nil,
+	)
+)
+
+type publisher struct {
+	transport.Publisher
+}
+
+// Publisher measures the messages published through p.
+func Publisher(p transport.Publisher) transport.Publisher {
+	return publisher{p}
+}
+
+func (p publisher) Publish(ctx context.Context, msg transport.Message) error {
+	sendInFlight.Inc()
+	defer sendInFlight.Dec()
+
+	start := time.Now()
+	err := p.Publisher.Publish(ctx, msg)
+	sendDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		sendErrors.Inc()
+	}
+	return err
+}
+
+// ObserveMessage records a consumed message and the time its handler took.
+func ObserveMessage(action, outcome string, took time.Duration) {
+	messages.WithLabelValues(action, outcome).Inc()
+	handleDuration.WithLabelValues(action).Observe(took.Seconds())
+}
+
+// RegisterLag exports the lag of a subscriber implementing
+// transport.Checker, asking it on every scrape. Other subscribers are
+// ignored.
+func RegisterLag(subscriber any, timeout time.Duration) {
+	if checker, ok := subscriber.(transport.Checker); ok {
+		registry.MustRegister(lagCollector{checker: checker, timeout: timeout})
+	}
+}
+
+type lagCollector struct {
+	checker transport.Checker
+	timeout time.Duration
+}
+
+func (c lagCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lagDesc
+}
+
+// Collect exports nothing while the lag is unknown, e.g. during a rebalance,
+// rather than a misleading zero.
+func (c lagCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	status, err := c.checker.Check(ctx)
+	if err != nil {
+		return
+	}
+	if len(status.PartitionLag) > 0 {
+		for partition, lag := range status.PartitionLag {
+			ch <- prometheus.MustNewConstMetric(lagDesc, prometheus.GaugeValue, float64(lag), strconv.Itoa(int(partition)))
+		}
+		return
+	}
+	if status.Lag != nil {
+		ch <- prometheus.MustNewConstMetric(lagDesc, prometheus.GaugeValue, float64(*status.Lag), "")
+	}
+}
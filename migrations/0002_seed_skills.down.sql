@@ -0,0 +1,7 @@
+-- Only remove the seeded rows nobody has changed since.
+DELETE FROM skill
+WHERE
+	(key = 'go' AND name = 'Go' AND description = 'Go is an open source programming...')
+	OR (key = 'figma' AND name = 'Figma' AND description = 'Figma is a vector graphics editor and prototyping tool which is primarily web-based, with additional offline features enabled by desktop applications for macOS and Windows.')
+	OR (key = 'html5' AND name = 'HTML5' AND description = 'HTML5 is a markup language used for structuring and presenting content on the World Wide Web.')
+	OR (key = 'negotiation' AND name = 'Negotiation' AND description = 'Negotiation is a dialogue between two or more people or parties intended to reach a beneficial outcome over one or more issues where a conflict exists with respect to at least one of these issues.');
@@ -1,11 +1,3 @@
-CREATE TABLE IF NOT EXISTS skill (
-	key TEXT PRIMARY KEY,
-	name TEXT NOT NULL DEFAULT '',
-	description TEXT NOT NULL DEFAULT '',
-	logo TEXT NOT NULL DEFAULT '',
-	tags TEXT [] NOT NULL DEFAULT '{}'
-);
-
 INSERT INTO
 	skill (key, name, description, tags)
 VALUES
@@ -14,11 +6,7 @@ VALUES
 		'Go',
 		'Go is an open source programming...',
 		'{go,golang}'
-	) ON CONFLICT (key) DO UPDATE
-SET
-	name = EXCLUDED.name,
-	description = EXCLUDED.description,
-	tags = EXCLUDED.tags;
+	) ON CONFLICT (key) DO NOTHING;
 
 INSERT INTO
 	skill (key, name, description)
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS skill_revision (
+	key TEXT NOT NULL,
+	rev INTEGER NOT NULL,
+	event_id TEXT NOT NULL DEFAULT '',
+	action TEXT NOT NULL DEFAULT '',
+	actor TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	logo TEXT NOT NULL DEFAULT '',
+	tags TEXT [] NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (key, rev)
+);
+
+CREATE INDEX IF NOT EXISTS skill_revision_event_id_idx ON skill_revision (event_id);
@@ -0,0 +1 @@
+ALTER TABLE skill DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1 @@
+ALTER TABLE skill ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
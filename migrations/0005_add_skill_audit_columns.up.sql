@@ -0,0 +1,5 @@
+ALTER TABLE skill
+	ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	ADD COLUMN IF NOT EXISTS created_by TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS updated_by TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+-- Token buckets of the API rate limiter, shared by its replicas. tat is the
+-- theoretical arrival time, in Unix nanoseconds, at which the bucket is full
+-- again; rows whose tat has passed can be dropped.
+CREATE TABLE IF NOT EXISTS rate_limit (
+	key TEXT PRIMARY KEY,
+	tat BIGINT NOT NULL
+);
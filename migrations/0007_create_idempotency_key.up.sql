@@ -0,0 +1,11 @@
+-- Responses of write requests sent with an Idempotency-Key header, replayed
+-- when the request is retried. status is 0 while the first request is being
+-- handled. expires_at is in Unix nanoseconds.
+CREATE TABLE IF NOT EXISTS idempotency_key (
+	key TEXT PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	status INTEGER NOT NULL DEFAULT 0,
+	content_type TEXT NOT NULL DEFAULT '',
+	body TEXT NOT NULL DEFAULT '',
+	expires_at BIGINT NOT NULL
+);
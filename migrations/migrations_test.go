@@ -0,0 +1,278 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected version %d, got %d (%s)", i+1, m.Version, m.Name)
+		}
+	}
+}
+
+func TestLoadRejectsMissingDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_create.up.sql":   {Data: []byte("CREATE TABLE t (id INTEGER);")},
+		"0001_create.down.sql": {Data: []byte("DROP TABLE t;")},
+		"0002_alter.up.sql":    {Data: []byte("ALTER TABLE t ADD COLUMN name TEXT;")},
+	}
+
+	if _, err := load(fsys); err == nil {
+		t.Error("Expected an error for a migration without a down file")
+	}
+}
+
+func TestLoadRejectsBadName(t *testing.T) {
+	fsys := fstest.MapFS{
+		"create.sql": {Data: []byte("CREATE TABLE t (id INTEGER);")},
+	}
+
+	if _, err := load(fsys); err == nil {
+		t.Error("Expected an error for a file without a version")
+	}
+}
+
+func migrationFiles(versions ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, v := range versions {
+		fsys[v+"_create_t"+v+".up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE t" + v + " (id INTEGER);")}
+		fsys[v+"_create_t"+v+".down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE t" + v + ";")}
+	}
+	return fsys
+}
+
+// testMigrator runs the migrations in fsys on db, an in-memory SQLite
+// database unless given.
+func testMigrator(t *testing.T, db *sql.DB, fsys fs.FS) (*Migrator, *sql.DB) {
+	t.Helper()
+	if db == nil {
+		var err error
+		if db, err = sql.Open("sqlite", ":memory:"); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		db.SetMaxOpenConns(1)
+	}
+
+	migrations, err := load(fsys)
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	return &Migrator{db: db, migrations: migrations}, db
+}
+
+// state returns the applied versions, as recorded by Status, and the tables
+// the migrations created.
+func state(t *testing.T, m *Migrator) (applied []int, tables []string) {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status error: %v", err)
+	}
+	for i, s := range statuses {
+		if i > 0 && statuses[i-1].Version >= s.Version {
+			t.Errorf("Expected statuses ordered by version, got %d before %d", statuses[i-1].Version, s.Version)
+		}
+		if s.Applied {
+			if s.AppliedAt.IsZero() {
+				t.Errorf("Expected version %d to have an applied time", s.Version)
+			}
+			applied = append(applied, s.Version)
+		}
+	}
+
+	rows, err := m.db.Query("SELECT name FROM sqlite_master WHERE type='table' AND name LIKE 't%' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	return applied, tables
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	m, _ := testMigrator(t, nil, migrationFiles("0001", "0002", "0003"))
+
+	for _, step := range []struct {
+		name    string
+		run     func() error
+		applied []int
+		tables  []string
+	}{
+		{"up", func() error { return m.Up(ctx) }, []int{1, 2, 3}, []string{"t0001", "t0002", "t0003"}},
+		{"up again", func() error { return m.Up(ctx) }, []int{1, 2, 3}, []string{"t0001", "t0002", "t0003"}},
+		{"down", func() error { return m.Down(ctx) }, []int{1, 2}, []string{"t0001", "t0002"}},
+		{"to 3", func() error { return m.To(ctx, 3) }, []int{1, 2, 3}, []string{"t0001", "t0002", "t0003"}},
+		{"to 1", func() error { return m.To(ctx, 1) }, []int{1}, []string{"t0001"}},
+		{"down to nothing", func() error { return m.Down(ctx) }, nil, nil},
+		{"down with nothing applied", func() error { return m.Down(ctx) }, nil, nil},
+		{"to 2", func() error { return m.To(ctx, 2) }, []int{1, 2}, []string{"t0001", "t0002"}},
+		{"to 0", func() error { return m.To(ctx, 0) }, nil, nil},
+	} {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		applied, tables := state(t, m)
+		if !reflect.DeepEqual(applied, step.applied) || !reflect.DeepEqual(tables, step.tables) {
+			t.Errorf("%s: expected %v applied and tables %v, got %v and %v", step.name, step.applied, step.tables, applied, tables)
+		}
+	}
+
+	if err := m.To(ctx, 4); err == nil {
+		t.Error("Expected an unknown version to be refused")
+	}
+}
+
+func TestMigratorAppliesSkippedVersion(t *testing.T) {
+	ctx := context.Background()
+	// Version 2 comes from a branch merged after 3 was applied.
+	old, db := testMigrator(t, nil, migrationFiles("0001", "0003"))
+	if err := old.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	m, _ := testMigrator(t, db, migrationFiles("0001", "0002", "0003"))
+	if applied, _ := state(t, m); !reflect.DeepEqual(applied, []int{1, 3}) {
+		t.Fatalf("Expected 1 and 3 applied, got %v", applied)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if applied, tables := state(t, m); !reflect.DeepEqual(applied, []int{1, 2, 3}) || len(tables) != 3 {
+		t.Errorf("Expected the skipped version to be applied, got %v and %v", applied, tables)
+	}
+}
+
+func TestMigratorRollsBackFailedMigration(t *testing.T) {
+	fsys := migrationFiles("0001", "0002", "0003")
+	fsys["0002_create_t0002.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE t0002 (id INTEGER); INSERT INTO missing VALUES (1);")}
+	m, _ := testMigrator(t, nil, fsys)
+
+	if err := m.Up(context.Background()); err == nil {
+		t.Fatal("Expected the failing migration to stop Up")
+	}
+	if applied, tables := state(t, m); !reflect.DeepEqual(applied, []int{1}) || !reflect.DeepEqual(tables, []string{"t0001"}) {
+		t.Errorf("Expected only version 1 applied and kept, got %v and %v", applied, tables)
+	}
+}
+
+// TestMigrationsPostgres runs the real migrations, which are written for
+// Postgres, when TEST_DATABASE_URL points at a database it may wipe.
+func TestMigrationsPostgres(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	m, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied := func() []int {
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			t.Fatalf("Status error: %v", err)
+		}
+		var versions []int
+		for _, s := range statuses {
+			if s.Applied {
+				versions = append(versions, s.Version)
+			}
+		}
+		return versions
+	}
+	all := make([]int, m.Latest())
+	for i := range all {
+		all[i] = i + 1
+	}
+
+	if err := m.To(ctx, 0); err != nil {
+		t.Fatalf("To 0 error: %v", err)
+	}
+	if got := applied(); got != nil {
+		t.Fatalf("Expected nothing applied, got %v", got)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("Up error: %v", err)
+	}
+	if got := applied(); !reflect.DeepEqual(got, all) {
+		t.Errorf("Expected %v applied, got %v", all, got)
+	}
+	if err := m.Down(ctx); err != nil {
+		t.Fatalf("Down error: %v", err)
+	}
+	if got := applied(); !reflect.DeepEqual(got, all[:len(all)-1]) {
+		t.Errorf("Expected %v applied, got %v", all[:len(all)-1], got)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("Up error: %v", err)
+	}
+
+	// The seed migration keeps skills that were there before it, as in a
+	// database set up by the old init.sql, and rolling it back only removes
+	// the seeded rows nobody changed.
+	if err := m.To(ctx, 1); err != nil {
+		t.Fatalf("To 1 error: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO skill (key, name) VALUES ('go', 'Golang')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.To(ctx, 2); err != nil {
+		t.Fatalf("To 2 error: %v", err)
+	}
+	var name string
+	if err := db.QueryRow(`SELECT name FROM skill WHERE key = 'go'`).Scan(&name); err != nil || name != "Golang" {
+		t.Errorf("Expected the existing go to be kept, got %q, %v", name, err)
+	}
+	if err := m.To(ctx, 1); err != nil {
+		t.Fatalf("To 1 error: %v", err)
+	}
+	var keys []string
+	rows, err := db.Query(`SELECT key FROM skill ORDER BY key`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	if want := []string{"go"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Expected only the existing go to be left, got %v", keys)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatalf("Up error: %v", err)
+	}
+}
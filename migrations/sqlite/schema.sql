@@ -0,0 +1,45 @@
+-- SQLite version of the schema built by the Postgres migrations. Tags are
+-- stored as TEXT holding an array literal such as {"go","web"}, the format
+-- Postgres uses for its TEXT[] columns. Keep it in step with the migrations.
+CREATE TABLE IF NOT EXISTS skill (
+	key TEXT PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	logo TEXT NOT NULL DEFAULT '',
+	tags TEXT NOT NULL DEFAULT '{}',
+	deleted_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	created_by TEXT NOT NULL DEFAULT '',
+	updated_by TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS skill_revision (
+	key TEXT NOT NULL,
+	rev INTEGER NOT NULL,
+	event_id TEXT NOT NULL DEFAULT '',
+	action TEXT NOT NULL DEFAULT '',
+	actor TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	logo TEXT NOT NULL DEFAULT '',
+	tags TEXT NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (key, rev)
+);
+
+CREATE INDEX IF NOT EXISTS skill_revision_event_id_idx ON skill_revision (event_id);
+
+CREATE TABLE IF NOT EXISTS rate_limit (
+	key TEXT PRIMARY KEY,
+	tat BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS idempotency_key (
+	key TEXT PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	status INTEGER NOT NULL DEFAULT 0,
+	content_type TEXT NOT NULL DEFAULT '',
+	body TEXT NOT NULL DEFAULT '',
+	expires_at BIGINT NOT NULL
+);
@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps the buckets of a single replica.
+type Memory struct {
+	mu   sync.Mutex
+	tats map[string]time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{tats: map[string]time.Time{}}
+}
+
+func (m *Memory) Take(_ context.Context, key string, now time.Time, interval, period time.Duration) (time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tat, ok := take(m.tats[key], now, interval, period)
+	if ok {
+		m.tats[key] = tat
+	}
+	return tat, ok, nil
+}
+
+func (m *Memory) Prune(_ context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, tat := range m.tats {
+		if !tat.After(now) {
+			delete(m.tats, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package ratelimit limits how often each client may call the skill API, with
+// one token bucket for reads and another for writes. The buckets are kept in
+// memory, per replica, or in the database shared by every replica.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/narunart-atise/skill-api-kafka/auth"
+	"github.com/narunart-atise/skill-api-kafka/config"
+)
+
+// Store keeps the buckets. A bucket is a single time, its theoretical arrival
+// time or tat: the time at which it is full again. Taking a token pushes the
+// tat one interval later, which is allowed while the tat stays within a
+// period of now. Buckets whose tat has passed are full and may be forgotten.
+type Store interface {
+	// Take takes a token from the bucket key if it has one. It returns the tat
+	// of the bucket after the attempt.
+	Take(ctx context.Context, key string, now time.Time, interval, period time.Duration) (tat time.Time, ok bool, err error)
+	// Prune forgets the buckets that are full at now.
+	Prune(ctx context.Context, now time.Time) error
+}
+
+// take is the bucket arithmetic shared by the stores.
+func take(tat, now time.Time, interval, period time.Duration) (time.Time, bool) {
+	if tat.Before(now) {
+		tat = now
+	}
+	if next := tat.Add(interval); next.Sub(now) <= period {
+		return next, true
+	}
+	return tat, false
+}
+
+// Limiter limits the requests of each client as configured.
+type Limiter struct {
+	store                 Store
+	period                time.Duration
+	read, write, failures int
+}
+
+// New returns a limiter keeping its buckets in store.
+func New(cfg config.RateLimit, store Store) *Limiter {
+	return &Limiter{store: store, period: cfg.Period, read: cfg.Read, write: cfg.Write, failures: cfg.Failures}
+}
+
+// Run forgets full buckets every period until ctx is cancelled.
+func (l *Limiter) Run(ctx context.Context) {
+	if l.read == 0 && l.write == 0 && l.failures == 0 {
+		return
+	}
+	ticker := time.NewTicker(l.period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if err := l.store.Prune(ctx, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "Pruning rate limit buckets failed", "error", err)
+		}
+	}
+}
+
+// Gin takes a token from the client's read bucket for GET and HEAD requests
+// and from its write bucket for the others, rejecting the request with 429
+// when the bucket is empty. It runs after auth.Gin, which names the client.
+//
+// Responses carry the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset
+// and RateLimit-Policy headers of the IETF draft, Reset being the seconds
+// until the bucket is full again. Requests are let through when the store
+// fails, a limiter outage should not take the API down.
+func (l *Limiter) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bucket, limit := "write", l.write
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			bucket, limit = "read", l.read
+		}
+		if limit == 0 {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := bucket + ":" + auth.Client(c)
+		interval := l.period / time.Duration(limit)
+		now := time.Now()
+		tat, ok, err := l.store.Take(ctx, key, now, interval, l.period)
+		if err != nil {
+			slog.ErrorContext(ctx, "Rate limiting failed, letting the request through", "key", key, "error", err)
+			c.Next()
+			return
+		}
+
+		wait := tat.Sub(now)
+		remaining := max(0, int((l.period-wait)/interval))
+		c.Header("RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("RateLimit-Reset", seconds(wait))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%s", limit, seconds(l.period)))
+		if !ok {
+			c.Header("Retry-After", seconds(wait+interval-l.period))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"status": "error", "message": "rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GinFailures limits the requests of each address that auth.Gin refuses, so
+// API keys and tokens cannot be guessed at full speed. It runs ahead of
+// auth.Gin: every 401 takes a token from the address's bucket, and once the
+// bucket is empty the address's requests get 429 before their credentials
+// are checked. Like Gin, it lets requests through when the store fails.
+func (l *Limiter) GinFailures() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l.failures == 0 {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := "failures:ip:" + c.ClientIP()
+		interval := l.period / time.Duration(l.failures)
+		// Taking with a zero interval reads the bucket without emptying it.
+		now := time.Now()
+		tat, _, err := l.store.Take(ctx, key, now, 0, l.period)
+		if err != nil {
+			slog.ErrorContext(ctx, "Rate limiting failed, letting the request through", "key", key, "error", err)
+			c.Next()
+			return
+		}
+		if wait := tat.Sub(now); wait+interval > l.period {
+			c.Header("Retry-After", seconds(wait+interval-l.period))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"status": "error", "message": "too many failed authentications"})
+			return
+		}
+
+		c.Next()
+
+		if c.Writer.Status() == http.StatusUnauthorized {
+			if _, _, err := l.store.Take(ctx, key, time.Now(), interval, l.period); err != nil {
+				slog.ErrorContext(ctx, "Rate limiting failed", "key", key, "error", err)
+			}
+		}
+	}
+}
+
+// seconds rounds d up to whole seconds, as the headers want.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
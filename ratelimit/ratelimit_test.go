@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/narunart-atise/skill-api-kafka/auth"
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/narunart-atise/skill-api-kafka/migrations"
+	_ "modernc.org/sqlite"
+)
+
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(migrations.SQLiteSchema); err != nil {
+		t.Fatalf("can't create tables: %v", err)
+	}
+	return map[string]Store{"memory": NewMemory(), "sql": NewSQL(db)}
+}
+
+func TestStores(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+
+	for name, s := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			// 3 per minute: a token every 20s, a burst of 3.
+			for i := 1; i <= 3; i++ {
+				tat, ok, err := s.Take(ctx, "write:alice", now, 20*time.Second, time.Minute)
+				if err != nil || !ok || !tat.Equal(now.Add(time.Duration(i)*20*time.Second)) {
+					t.Fatalf("take %d: expected a token, got %v, %v, %v", i, tat, ok, err)
+				}
+			}
+			tat, ok, err := s.Take(ctx, "write:alice", now.Add(time.Second), 20*time.Second, time.Minute)
+			if err != nil || ok || !tat.Equal(now.Add(time.Minute)) {
+				t.Errorf("Expected the bucket to be empty until it refills, got %v, %v, %v", tat, ok, err)
+			}
+			if _, ok, _ := s.Take(ctx, "write:bob", now, 20*time.Second, time.Minute); !ok {
+				t.Error("Expected bob to have a bucket of their own")
+			}
+			if _, ok, _ := s.Take(ctx, "write:alice", now.Add(20*time.Second), 20*time.Second, time.Minute); !ok {
+				t.Error("Expected a token once one interval has passed")
+			}
+
+			if err := s.Prune(ctx, now.Add(time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			// bob's bucket is full and forgotten; alice's is not.
+			if tat, _, _ := s.Take(ctx, "write:alice", now.Add(time.Minute), 20*time.Second, time.Minute); !tat.Equal(now.Add(100 * time.Second)) {
+				t.Errorf("Expected alice's bucket to be kept, got %v", tat)
+			}
+		})
+	}
+}
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := New(config.RateLimit{Read: 0, Write: 2, Period: time.Minute}, NewMemory())
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if name := c.GetHeader("X-Principal"); name != "" {
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), auth.Principal{Subject: name, Method: "api_key"}))
+		}
+	}, l.Gin())
+	r.GET("/api/v1/skills", func(c *gin.Context) {})
+	r.PATCH("/api/v1/skills/:key/actions/tags", func(c *gin.Context) {})
+
+	do := func(method, principal, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/skills", nil)
+		if method == http.MethodPatch {
+			req = httptest.NewRequest(method, "/api/v1/skills/go/actions/tags", nil)
+		}
+		req.RemoteAddr = ip + ":1234"
+		if principal != "" {
+			req.Header.Set("X-Principal", principal)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		w := do(http.MethodPatch, "script", "10.0.0.1")
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != remaining {
+			t.Errorf("write %d: expected 200 with %s remaining, got %d with %q", i, remaining, w.Code, w.Header().Get("RateLimit-Remaining"))
+		}
+	}
+	w := do(http.MethodPatch, "script", "10.0.0.2")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" || w.Header().Get("RateLimit-Policy") != "2;w=60" {
+		t.Errorf("Expected the key to be limited wherever it calls from, got %d %v", w.Code, w.Header())
+	}
+
+	if w := do(http.MethodGet, "script", "10.0.0.1"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("Expected reads to be unlimited, got %d %v", w.Code, w.Header())
+	}
+	if w := do(http.MethodPatch, "", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Errorf("Expected anonymous callers to be limited by address, got %d", w.Code)
+	}
+	if w := do(http.MethodPatch, "other", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Errorf("Expected another key to have its own bucket, got %d", w.Code)
+	}
+}
+
+func TestGinFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := New(config.RateLimit{Failures: 2, Period: time.Minute}, NewMemory())
+
+	r := gin.New()
+	r.Use(l.GinFailures(), func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") != "right" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		}
+	})
+	r.GET("/api/v1/skills", func(c *gin.Context) {})
+
+	do := func(key, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/skills", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := do("right", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("Expected authenticated requests not to count, got %d", w.Code)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if w := do("wrong", "10.0.0.1"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: expected 401, got %d", i, w.Code)
+		}
+	}
+	w := do("right", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Errorf("Expected the address to be refused before authentication, got %d %v", w.Code, w.Header())
+	}
+	if w := do("wrong", "10.0.0.2"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected another address to have its own bucket, got %d", w.Code)
+	}
+}
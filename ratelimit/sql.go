@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// takeQuery applies take to the rate_limit row of $1 in one statement, so
+// replicas racing for the same bucket cannot both take its last token. Times
+// are Unix nanoseconds. No row is returned when the bucket is empty.
+const takeQuery = `INSERT INTO rate_limit (key, tat) VALUES ($1, $5)
+ON CONFLICT (key) DO UPDATE SET tat = CASE WHEN rate_limit.tat > $2 THEN rate_limit.tat ELSE $2 END + $3
+WHERE CASE WHEN rate_limit.tat > $2 THEN rate_limit.tat ELSE $2 END + $3 - $2 <= $4
+RETURNING tat`
+
+// SQL keeps the buckets in the rate_limit table of a Postgres or SQLite
+// database, shared by the replicas using it.
+type SQL struct {
+	db *sql.DB
+}
+
+func NewSQL(db *sql.DB) *SQL {
+	return &SQL{db: db}
+}
+
+func (s *SQL) Take(ctx context.Context, key string, now time.Time, interval, period time.Duration) (time.Time, bool, error) {
+	n := now.UnixNano()
+	var tat int64
+	err := s.db.QueryRowContext(ctx, takeQuery, key, n, int64(interval), int64(period), n+int64(interval)).Scan(&tat)
+	if err == nil {
+		return time.Unix(0, tat), true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, err
+	}
+
+	if err := s.db.QueryRowContext(ctx, "SELECT tat FROM rate_limit WHERE key=$1", key).Scan(&tat); err != nil {
+		return time.Time{}, false, err
+	}
+	return time.Unix(0, max(tat, n)), false, nil
+}
+
+func (s *SQL) Prune(ctx context.Context, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM rate_limit WHERE tat <= $1", now.UnixNano())
+	return err
+}
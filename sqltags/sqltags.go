@@ -0,0 +1,107 @@
+// Package sqltags stores a list of tags in one column of either database the
+// services support.
+package sqltags
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Array stores tags as an array literal such as {"go","web"}. Postgres
+// reads it into its TEXT[] column and SQLite keeps it as TEXT, so the same
+// queries work on both.
+type Array []string
+
+func (a Array) Value() (driver.Value, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, tag := range a {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('"')
+		for _, r := range tag {
+			if r == '"' || r == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+func (a *Array) Scan(src any) error {
+	var s string
+	switch src := src.(type) {
+	case string:
+		s = src
+	case []byte:
+		s = string(src)
+	case nil:
+		*a = Array{}
+		return nil
+	default:
+		return fmt.Errorf("tags: cannot scan %T", src)
+	}
+
+	tags, err := parseTags(s)
+	if err != nil {
+		return fmt.Errorf("tags: %w in %q", err, s)
+	}
+	*a = tags
+	return nil
+}
+
+// parseTags reads a one-dimensional array literal. Elements may be bare or
+// double quoted with backslash escapes; unquoted NULL elements are dropped.
+func parseTags(s string) ([]string, error) {
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, errors.New("not an array literal")
+	}
+	body := s[1 : len(s)-1]
+
+	tags := []string{}
+	if body == "" {
+		return tags, nil
+	}
+
+	for i := 0; ; {
+		var tag strings.Builder
+		quoted := i < len(body) && body[i] == '"'
+		if quoted {
+			i++
+			for ; i < len(body) && body[i] != '"'; i++ {
+				if body[i] == '\\' {
+					i++
+				}
+				if i < len(body) {
+					tag.WriteByte(body[i])
+				}
+			}
+			if i >= len(body) {
+				return nil, errors.New("unterminated quote")
+			}
+			i++
+		} else {
+			for ; i < len(body) && body[i] != ','; i++ {
+				tag.WriteByte(body[i])
+			}
+		}
+
+		if element := tag.String(); quoted || element != "NULL" {
+			tags = append(tags, element)
+		}
+
+		if i >= len(body) {
+			return tags, nil
+		}
+		if body[i] != ',' {
+			return nil, errors.New("expected a comma")
+		}
+		i++
+	}
+}
@@ -0,0 +1,53 @@
+package sqltags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArrayRoundTrip(t *testing.T) {
+	for _, tags := range [][]string{
+		{},
+		{"go", "web"},
+		{"with,comma", `with "quote"`, `back\slash`, "with space", "NULL", ""},
+	} {
+		v, err := Array(tags).Value()
+		if err != nil {
+			t.Fatalf("Value(%q) error: %v", tags, err)
+		}
+		var got Array
+		if err := got.Scan(v); err != nil {
+			t.Fatalf("Scan(%q) error: %v", v, err)
+		}
+		if !reflect.DeepEqual([]string(got), tags) {
+			t.Errorf("Expected %q back, got %q", tags, got)
+		}
+	}
+}
+
+func TestArrayScan(t *testing.T) {
+	tests := []struct {
+		src  any
+		want Array
+	}{
+		{nil, Array{}},
+		{"{}", Array{}},
+		{[]byte("{go,web}"), Array{"go", "web"}},
+		{`{go,NULL,"NULL"}`, Array{"go", "NULL"}},
+	}
+	for _, tt := range tests {
+		var got Array
+		if err := got.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%q) error: %v", tt.src, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Scan(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+
+	for _, src := range []any{"go", `{"go`, `{"go"x}`, 42} {
+		var got Array
+		if err := got.Scan(src); err == nil {
+			t.Errorf("Expected Scan(%v) to fail, got %q", src, got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/narunart-atise/skill-api-kafka/transport"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestPropagation(t *testing.T) {
+	recorder := record(t)
+	bus := transport.NewBus(time.Millisecond)
+
+	ctx, request := Start(context.Background(), "request")
+	msg := transport.Message{Key: "go", Headers: map[string]string{"other": "kept"}}
+	if err := Publisher(bus.Publisher()).Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	request.End()
+	if msg.Headers["traceparent"] != "" {
+		t.Error("Expected the caller's headers to be left alone")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got transport.Message
+	var handled trace.SpanContext
+	handle := Handler(func(ctx context.Context, msg transport.Message) error {
+		got, handled = msg, trace.SpanContextFromContext(ctx)
+		cancel()
+		return nil
+	})
+	if err := bus.Subscriber().Subscribe(ctx, handle); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Headers["traceparent"] == "" || got.Headers["other"] != "kept" {
+		t.Errorf("Expected the trace context next to the other headers, got %v", got.Headers)
+	}
+	if handled.TraceID() != request.SpanContext().TraceID() {
+		t.Error("Expected the consumer to continue the request's trace")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected request, publish and process spans, got %d", len(spans))
+	}
+	publish, process := spans[0], spans[2]
+	if publish.SpanKind() != trace.SpanKindProducer || publish.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("Expected a producer span under the request, got %s under %s", publish.SpanKind(), publish.Parent().SpanID())
+	}
+	if process.SpanKind() != trace.SpanKindConsumer || process.Parent().SpanID() != publish.SpanContext().SpanID() {
+		t.Errorf("Expected a consumer span under the publish, got %s under %s", process.SpanKind(), process.Parent().SpanID())
+	}
+}
+
+func TestHandlerRecordsErrors(t *testing.T) {
+	recorder := record(t)
+
+	handle := Handler(func(context.Context, transport.Message) error { return errors.New("database down") })
+	if err := handle(context.Background(), transport.Message{}); err == nil {
+		t.Fatal("Expected the handler error")
+	}
+
+	if spans := recorder.Ended(); len(spans) != 1 || spans[0].Status().Code != codes.Error {
+		t.Errorf("Expected one failed span, got %+v", spans)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{"none", "stdout"} {
+		shutdown, err := Setup(context.Background(), config.Tracing{Exporter: exporter}, "test")
+		if err != nil {
+			t.Fatalf("Setup %s error: %v", exporter, err)
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Errorf("Shutdown %s error: %v", exporter, err)
+		}
+	}
+
+	if _, err := Setup(context.Background(), config.Tracing{Exporter: "zipkin"}, "test"); err == nil {
+		t.Error("Expected an unknown exporter to be refused")
+	}
+}
@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/narunart-atise/skill-api-kafka/transport"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type publisher struct {
+	transport.Publisher
+}
+
+// Publisher traces the messages published through p and adds the trace
+// context of each to its headers.
+func Publisher(p transport.Publisher) transport.Publisher {
+	return publisher{p}
+}
+
+func (p publisher) Publish(ctx context.Context, msg transport.Message) (err error) {
+	ctx, span := otel.Tracer(instrumentation).Start(ctx, "publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(semconv.MessagingOperationPublish, attribute.String("skill.key", msg.Key)),
+	)
+	defer func() { End(span, err) }()
+
+	// The caller's headers are copied rather than written to.
+	headers := make(map[string]string, len(msg.Headers)+2)
+	for name, value := range msg.Headers {
+		headers[name] = value
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+	msg.Headers = headers
+
+	return p.Publisher.Publish(ctx, msg)
+}
+
+// Handler continues the trace carried in the headers of each message and
+// traces every attempt at handling it.
+func Handler(handle transport.Handler) transport.Handler {
+	return func(ctx context.Context, msg transport.Message) (err error) {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+		ctx, span := otel.Tracer(instrumentation).Start(ctx, "process",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(semconv.MessagingOperationDeliver, attribute.String("skill.key", msg.Key)),
+		)
+		defer func() { End(span, err) }()
+
+		return handle(ctx, msg)
+	}
+}
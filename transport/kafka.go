@@ -0,0 +1,304 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/narunart-atise/skill-api-kafka/config"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+type kafkaPublisher struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+	topic    string
+	closed   atomic.Bool
+}
+
+func NewKafkaPublisher(cfg config.Kafka) (Publisher, error) {
+	saramaConfig, err := cfg.Sarama()
+	if err != nil {
+		return nil, err
+	}
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Return.Errors = true
+
+	client, err := sarama.NewClient(cfg.Brokers, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &kafkaPublisher{client: client, producer: producer, topic: cfg.Topic}, nil
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, msg Message) error {
+	message := &sarama.ProducerMessage{
+		Topic: p.topic,
+		Value: sarama.ByteEncoder(msg.Value),
+	}
+	// The key picks the partition, which keeps commands for one skill in order.
+	if msg.Key != "" {
+		message.Key = sarama.StringEncoder(msg.Key)
+	}
+	for name, value := range msg.Headers {
+		message.Headers = append(message.Headers, sarama.RecordHeader{Key: []byte(name), Value: []byte(value)})
+	}
+
+	partition, offset, err := p.producer.SendMessage(message)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to send message", "key", msg.Key, "error", err)
+		return err
+	}
+
+	slog.DebugContext(ctx, "Message sent", "key", msg.Key, "partition", partition, "offset", offset)
+	return nil
+}
+
+// Check reports the producer unready once it is closed or when no broker
+// answers a metadata request for the topic.
+func (p *kafkaPublisher) Check(ctx context.Context) (Status, error) {
+	if p.closed.Load() {
+		return Status{}, errors.New("kafka: producer is closed")
+	}
+	return Status{}, kafkaReachable(ctx, p.client, p.topic)
+}
+
+func (p *kafkaPublisher) MetricRegistry() gometrics.Registry {
+	return p.client.Config().MetricRegistry
+}
+
+func (p *kafkaPublisher) Close() error {
+	p.closed.Store(true)
+	return errors.Join(p.producer.Close(), p.client.Close())
+}
+
+// kafkaReachable refreshes the metadata of topic, which takes a live broker.
+func kafkaReachable(ctx context.Context, client sarama.Client, topic string) error {
+	done := make(chan error, 1)
+	go func() { done <- client.RefreshMetadata(topic) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("kafka: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("kafka: %w", ctx.Err())
+	}
+}
+
+type kafkaSubscriber struct {
+	client     sarama.Client
+	group      sarama.ConsumerGroup
+	topic      string
+	backoff    time.Duration
+	assignment *assignment
+}
+
+// NewKafkaSubscriber joins the consumer group named by cfg.Consumer.Group, so
+// instances share the partitions and resume from committed offsets.
+func NewKafkaSubscriber(cfg config.Kafka, backoff time.Duration) (Subscriber, error) {
+	saramaConfig, err := cfg.Sarama()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sarama.NewClient(cfg.Brokers, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+	group, err := sarama.NewConsumerGroupFromClient(cfg.Consumer.Group, client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &kafkaSubscriber{client: client, group: group, topic: cfg.Topic, backoff: backoff, assignment: &assignment{}}, nil
+}
+
+func (s *kafkaSubscriber) Subscribe(ctx context.Context, handle Handler) error {
+	go func() {
+		for err := range s.group.Errors() {
+			slog.Error("Consumer group error", "error", err)
+		}
+	}()
+
+	handler := groupHandler{handle: handle, backoff: s.backoff, assignment: s.assignment}
+	for {
+		// Consume returns on every rebalance and has to be called again.
+		if err := s.group.Consume(ctx, []string{s.topic}, handler); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// Check reports the subscriber unready when no broker answers or while it is
+// outside a group session, i.e. before joining the group and during a
+// rebalance. A member left without partitions is ready, merely idle. Lag
+// covers the partitions whose position is known.
+func (s *kafkaSubscriber) Check(ctx context.Context) (Status, error) {
+	if err := kafkaReachable(ctx, s.client, s.topic); err != nil {
+		return Status{}, err
+	}
+	return s.assignment.status()
+}
+
+func (s *kafkaSubscriber) MetricRegistry() gometrics.Registry {
+	return s.client.Config().MetricRegistry
+}
+
+func (s *kafkaSubscriber) Close() error {
+	return errors.Join(s.group.Close(), s.client.Close())
+}
+
+// assignment tracks the partitions claimed in the current group session and
+// the next offset to process on each.
+type assignment struct {
+	mu     sync.Mutex
+	active bool
+	claims map[int32]*claimState
+}
+
+type claimState struct {
+	claim sarama.ConsumerGroupClaim
+	// next is the offset of the next message to process, -1 until known.
+	next int64
+}
+
+func (a *assignment) setup(partitions []int32) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.active = true
+	a.claims = map[int32]*claimState{}
+	for _, partition := range partitions {
+		a.claims[partition] = &claimState{next: -1}
+	}
+}
+
+func (a *assignment) cleanup() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.active = false
+	a.claims = nil
+}
+
+func (a *assignment) start(claim sarama.ConsumerGroupClaim) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if state, ok := a.claims[claim.Partition()]; ok {
+		state.claim = claim
+		// The initial offset is a sentinel, not a position, for a group
+		// without a committed offset.
+		if claim.InitialOffset() >= 0 {
+			state.next = claim.InitialOffset()
+		}
+	}
+}
+
+// at records that the message at offset on partition is next in line.
+func (a *assignment) at(partition int32, offset int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if state, ok := a.claims[partition]; ok {
+		state.next = offset
+	}
+}
+
+func (a *assignment) status() (Status, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.active {
+		return Status{}, errors.New("kafka: not in a group session, the group is still being joined or rebalancing")
+	}
+
+	status := Status{PartitionLag: map[int32]int64{}}
+	var lag int64
+	for partition, state := range a.claims {
+		status.Partitions = append(status.Partitions, partition)
+		if state.claim != nil && state.next >= 0 {
+			status.PartitionLag[partition] = max(0, state.claim.HighWaterMarkOffset()-state.next)
+			lag += status.PartitionLag[partition]
+		}
+	}
+	sort.Slice(status.Partitions, func(i, j int) bool { return status.Partitions[i] < status.Partitions[j] })
+	status.Lag = &lag
+	return status, nil
+}
+
+type groupHandler struct {
+	handle     Handler
+	backoff    time.Duration
+	assignment *assignment
+}
+
+func (h groupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	var partitions []int32
+	for _, claimed := range session.Claims() {
+		partitions = append(partitions, claimed...)
+	}
+	h.assignment.setup(partitions)
+	return nil
+}
+
+// Cleanup commits the offsets marked in the session, which is ending because
+// of a rebalance or a shutdown, rather than leave them to the next auto
+// commit.
+func (h groupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	h.assignment.cleanup()
+	session.Commit()
+	return nil
+}
+
+func (h groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	h.assignment.start(claim)
+	for {
+		select {
+		case m, ok := <-claim.Messages():
+			// select picks at random when a message is waiting too.
+			if !ok || session.Context().Err() != nil {
+				return nil
+			}
+
+			msg := Message{Key: string(m.Key), Value: m.Value}
+			if len(m.Headers) > 0 {
+				msg.Headers = make(map[string]string, len(m.Headers))
+				for _, header := range m.Headers {
+					msg.Headers[string(header.Key)] = string(header.Value)
+				}
+			}
+
+			h.assignment.at(m.Partition, m.Offset)
+			if err := retry(session.Context(), h.backoff, h.handle, msg); err != nil {
+				return nil
+			}
+			session.MarkMessage(m, "")
+			h.assignment.at(m.Partition, m.Offset+1)
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package transport_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/narunart-atise/skill-api-kafka/transport"
+	"github.com/narunart-atise/skill-api-kafka/transport/transporttest"
+)
+
+// TestKafkaConformance needs a real broker, e.g. the one from
+// docker-compose.yml: KAFKA_TEST_BROKERS=localhost:29092 go test ./...
+func TestKafkaConformance(t *testing.T) {
+	brokers := os.Getenv("KAFKA_TEST_BROKERS")
+	if brokers == "" {
+		t.Skip("KAFKA_TEST_BROKERS is not set")
+	}
+
+	kafkaConfig := func(t *testing.T, stream string) config.Kafka {
+		cfg, _, err := config.LoadConsumer([]string{"-database-url", "postgres://unused"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		k := cfg.Kafka
+		k.Brokers = strings.Split(brokers, ",")
+		k.Topic = stream
+		k.Topics.DeadLetter = ""
+		k.Topics.Result = ""
+		k.Consumer.Group = stream
+		if err := k.EnsureTopics(); err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	transporttest.Run(t, transporttest.Backend{
+		Publisher: func(t *testing.T, stream string) transport.Publisher {
+			p, err := transport.NewKafkaPublisher(kafkaConfig(t, stream))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return p
+		},
+		Subscriber: func(t *testing.T, stream string) transport.Subscriber {
+			s, err := transport.NewKafkaSubscriber(kafkaConfig(t, stream), 50*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+	})
+}
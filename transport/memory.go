@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Bus is an in-process broker for running the API and the consumer in one
+// process. It keeps messages in memory, so they are lost when the process
+// exits, and it serves one subscriber at a time: others wait for their turn.
+type Bus struct {
+	backoff time.Duration
+
+	mu    sync.Mutex
+	queue []Message
+	ready chan struct{}
+	turn  chan struct{}
+}
+
+func NewBus(backoff time.Duration) *Bus {
+	return &Bus{backoff: backoff, ready: make(chan struct{}, 1), turn: make(chan struct{}, 1)}
+}
+
+func (b *Bus) Publisher() Publisher {
+	return busPublisher{b}
+}
+
+func (b *Bus) Subscriber() Subscriber {
+	return busSubscriber{b}
+}
+
+type busPublisher struct {
+	bus *Bus
+}
+
+func (p busPublisher) Publish(ctx context.Context, msg Message) error {
+	p.bus.mu.Lock()
+	p.bus.queue = append(p.bus.queue, msg)
+	p.bus.mu.Unlock()
+
+	select {
+	case p.bus.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (busPublisher) Check(ctx context.Context) (Status, error) { return Status{}, nil }
+
+func (busPublisher) Close() error { return nil }
+
+type busSubscriber struct {
+	bus *Bus
+}
+
+func (s busSubscriber) Subscribe(ctx context.Context, handle Handler) error {
+	b := s.bus
+	select {
+	case b.turn <- struct{}{}:
+		defer func() { <-b.turn }()
+	case <-ctx.Done():
+		return nil
+	}
+
+	for ctx.Err() == nil {
+		b.mu.Lock()
+		if len(b.queue) == 0 {
+			b.mu.Unlock()
+			select {
+			case <-b.ready:
+				continue
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		// The head stays queued until it is handled, so a message that is
+		// still failing when ctx is cancelled goes to the next subscriber.
+		msg := b.queue[0]
+		b.mu.Unlock()
+
+		if err := retry(ctx, b.backoff, handle, msg); err != nil {
+			return nil
+		}
+
+		b.mu.Lock()
+		b.queue = b.queue[1:]
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// Check reports the queued messages as lag.
+func (s busSubscriber) Check(ctx context.Context) (Status, error) {
+	s.bus.mu.Lock()
+	lag := int64(len(s.bus.queue))
+	s.bus.mu.Unlock()
+	return Status{Lag: &lag}, nil
+}
+
+func (busSubscriber) Close() error { return nil }
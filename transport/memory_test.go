@@ -0,0 +1,32 @@
+package transport_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/transport"
+	"github.com/narunart-atise/skill-api-kafka/transport/transporttest"
+)
+
+func TestBusConformance(t *testing.T) {
+	var mu sync.Mutex
+	buses := map[string]*transport.Bus{}
+	bus := func(stream string) *transport.Bus {
+		mu.Lock()
+		defer mu.Unlock()
+		if buses[stream] == nil {
+			buses[stream] = transport.NewBus(50 * time.Millisecond)
+		}
+		return buses[stream]
+	}
+
+	transporttest.Run(t, transporttest.Backend{
+		Publisher: func(t *testing.T, stream string) transport.Publisher {
+			return bus(stream).Publisher()
+		},
+		Subscriber: func(t *testing.T, stream string) transport.Subscriber {
+			return bus(stream).Subscriber()
+		},
+	})
+}
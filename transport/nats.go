@@ -0,0 +1,192 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// keyHeader carries Message.Key, which NATS has no field for.
+const keyHeader = "Skill-Key"
+
+type natsPublisher struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+// connectJetStream connects to cfg.URL and creates the stream if it is
+// missing, so either side may start first.
+func connectJetStream(cfg config.NATS) (*nats.Conn, jetstream.JetStream, jetstream.Stream, error) {
+	conn, err := nats.Connect(cfg.URL)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("nats: connect: %w", err)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     cfg.Stream,
+		Subjects: []string{cfg.Subject},
+	})
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, fmt.Errorf("nats: create stream %s: %w", cfg.Stream, err)
+	}
+
+	return conn, js, stream, nil
+}
+
+func NewNATSPublisher(cfg config.NATS) (Publisher, error) {
+	conn, js, _, err := connectJetStream(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &natsPublisher{conn: conn, js: js, subject: cfg.Subject}, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, msg Message) error {
+	m := nats.NewMsg(p.subject)
+	m.Data = msg.Value
+	for name, value := range msg.Headers {
+		m.Header.Set(name, value)
+	}
+	if msg.Key != "" {
+		m.Header.Set(keyHeader, msg.Key)
+	}
+
+	_, err := p.js.PublishMsg(ctx, m)
+	return err
+}
+
+func (p *natsPublisher) Check(ctx context.Context) (Status, error) {
+	return Status{}, natsConnected(p.conn)
+}
+
+func natsConnected(conn *nats.Conn) error {
+	if status := conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("nats: connection is %s", status)
+	}
+	return nil
+}
+
+func (p *natsPublisher) Close() error {
+	p.conn.Close()
+	return nil
+}
+
+type natsSubscriber struct {
+	conn     *nats.Conn
+	consumer jetstream.Consumer
+	backoff  time.Duration
+}
+
+// NewNATSSubscriber binds to the durable consumer named by cfg.Durable. It
+// allows a single unacknowledged message, which keeps delivery in order.
+func NewNATSSubscriber(cfg config.NATS, backoff time.Duration) (Subscriber, error) {
+	conn, _, stream, err := connectJetStream(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	consumer, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       cfg.Durable,
+		FilterSubject: cfg.Subject,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+		MaxAckPending: 1,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("nats: create consumer %s: %w", cfg.Durable, err)
+	}
+
+	return &natsSubscriber{conn: conn, consumer: consumer, backoff: backoff}, nil
+}
+
+func (s *natsSubscriber) Subscribe(ctx context.Context, handle Handler) error {
+	messages, err := s.consumer.Messages()
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		messages.Stop()
+	}()
+
+	for {
+		m, err := messages.Next()
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Stop may come too late to keep this one from being fetched.
+		if ctx.Err() != nil {
+			m.Nak()
+			return nil
+		}
+
+		msg := Message{Value: m.Data()}
+		for name := range m.Headers() {
+			if name == keyHeader {
+				msg.Key = m.Headers().Get(name)
+				continue
+			}
+			if msg.Headers == nil {
+				msg.Headers = map[string]string{}
+			}
+			msg.Headers[name] = m.Headers().Get(name)
+		}
+
+		// JetStream redelivers a nak'd message itself, and holds back the next
+		// one meanwhile because only one may be pending.
+		if err := handle(ctx, msg); err != nil {
+			slog.WarnContext(ctx, "Failed to handle message, retrying", "key", msg.Key, "backoff", s.backoff, "error", err)
+			m.NakWithDelay(s.backoff)
+			continue
+		}
+		// Ack even when ctx was cancelled during handle, the work is done.
+		if err := m.DoubleAck(context.WithoutCancel(ctx)); err != nil {
+			return fmt.Errorf("nats: ack: %w", err)
+		}
+	}
+}
+
+// Check reports the messages on the stream not yet acknowledged by the
+// durable consumer as lag.
+func (s *natsSubscriber) Check(ctx context.Context) (Status, error) {
+	if err := natsConnected(s.conn); err != nil {
+		return Status{}, err
+	}
+
+	info, err := s.consumer.Info(ctx)
+	if err != nil {
+		return Status{}, fmt.Errorf("nats: consumer info: %w", err)
+	}
+	lag := int64(info.NumPending) + int64(info.NumAckPending)
+	return Status{Lag: &lag}, nil
+}
+
+func (s *natsSubscriber) Close() error {
+	s.conn.Close()
+	return nil
+}
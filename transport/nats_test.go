@@ -0,0 +1,50 @@
+package transport_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/narunart-atise/skill-api-kafka/transport"
+	"github.com/narunart-atise/skill-api-kafka/transport/transporttest"
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+func TestNATSConformance(t *testing.T) {
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Start()
+	defer srv.Shutdown()
+	if !srv.ReadyForConnections(10 * time.Second) {
+		t.Fatal("nats server did not start")
+	}
+
+	natsConfig := func(stream string) config.NATS {
+		return config.NATS{
+			URL:     srv.ClientURL(),
+			Stream:  stream,
+			Subject: stream + ".commands",
+			Durable: "conformance",
+			AckWait: 5 * time.Second,
+		}
+	}
+
+	transporttest.Run(t, transporttest.Backend{
+		Publisher: func(t *testing.T, stream string) transport.Publisher {
+			p, err := transport.NewNATSPublisher(natsConfig(stream))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return p
+		},
+		Subscriber: func(t *testing.T, stream string) transport.Subscriber {
+			s, err := transport.NewNATSSubscriber(natsConfig(stream), 50*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+	})
+}
@@ -0,0 +1,335 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// Stream entries hold the key and value under these fields and each header
+// under headerPrefix plus its name.
+const (
+	keyField     = "key"
+	valueField   = "value"
+	headerPrefix = "h:"
+)
+
+// redisBlock bounds each blocking read so a cancelled context is noticed.
+const redisBlock = time.Second
+
+func newRedisClient(cfg config.Redis) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+}
+
+type redisPublisher struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+func NewRedisPublisher(cfg config.Redis) Publisher {
+	return &redisPublisher{client: newRedisClient(cfg), stream: cfg.Stream, maxLen: cfg.MaxLen}
+}
+
+func (p *redisPublisher) Publish(ctx context.Context, msg Message) error {
+	values := map[string]any{keyField: msg.Key, valueField: msg.Value}
+	for name, value := range msg.Headers {
+		values[headerPrefix+name] = value
+	}
+
+	return p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.stream,
+		MaxLen: p.maxLen,
+		Approx: p.maxLen > 0,
+		Values: values,
+	}).Err()
+}
+
+func (p *redisPublisher) Check(ctx context.Context) (Status, error) {
+	if err := p.client.Ping(ctx).Err(); err != nil {
+		return Status{}, fmt.Errorf("redis: %w", err)
+	}
+	return Status{}, nil
+}
+
+func (p *redisPublisher) Close() error {
+	return p.client.Close()
+}
+
+type redisSubscriber struct {
+	client   *redis.Client
+	stream   string
+	group    string
+	consumer string
+	lease    time.Duration
+	backoff  time.Duration
+}
+
+// NewRedisSubscriber reads cfg.Stream as a member of the consumer group
+// cfg.Group, creating both if they are missing.
+//
+// Redis hands the entries of a stream to whichever member of a group asks
+// first, which would let two instances apply commands for one key out of
+// order. Members therefore take turns: the one holding the group's lease
+// reads the stream and the others wait to take over when it stops renewing
+// it. Consumer names must be unique within the group.
+func NewRedisSubscriber(cfg config.Redis, backoff time.Duration) (Subscriber, error) {
+	consumer := cfg.Consumer
+	if consumer == "" {
+		consumer, _ = os.Hostname()
+	}
+
+	client := newRedisClient(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := client.XGroupCreateMkStream(ctx, cfg.Stream, cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		client.Close()
+		return nil, fmt.Errorf("redis: create group %s: %w", cfg.Group, err)
+	}
+
+	return &redisSubscriber{
+		client:   client,
+		stream:   cfg.Stream,
+		group:    cfg.Group,
+		consumer: consumer,
+		lease:    cfg.Lease,
+		backoff:  backoff,
+	}, nil
+}
+
+// acquireLease takes the lease when it is free and extends it when this
+// consumer holds it already, returning 1 in both cases.
+var acquireLease = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+if not owner then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0`)
+
+// releaseLease gives the lease up if this consumer still holds it.
+var releaseLease = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+
+var errLeaseLost = errors.New("redis: lease lost")
+
+func (s *redisSubscriber) leaseKey() string {
+	return s.stream + ":" + s.group + ":lease"
+}
+
+func (s *redisSubscriber) renew(ctx context.Context) (bool, error) {
+	held, err := acquireLease.Run(ctx, s.client, []string{s.leaseKey()}, s.consumer, s.lease.Milliseconds()).Int()
+	return held == 1, err
+}
+
+func (s *redisSubscriber) Subscribe(ctx context.Context, handle Handler) error {
+	for ctx.Err() == nil {
+		held, err := s.renew(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("redis: lease: %w", err)
+		}
+		if !held {
+			select {
+			case <-time.After(s.lease / 3):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		if err := s.consume(ctx, handle); !errors.Is(err, errLeaseLost) {
+			return err
+		}
+		slog.WarnContext(ctx, "Lost the stream lease, standing by", "stream", s.stream, "consumer", s.consumer)
+	}
+	return nil
+}
+
+// consume reads the stream while the lease is held, renewing it in the
+// background, and gives it up when done. It returns errLeaseLost when another
+// consumer took the lease over.
+func (s *redisSubscriber) consume(ctx context.Context, handle Handler) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	var renewing sync.WaitGroup
+	renewing.Add(1)
+	go func() {
+		defer renewing.Done()
+		s.keepLease(ctx, cancel)
+	}()
+	defer func() {
+		cancel(nil)
+		renewing.Wait()
+		releaseLease.Run(context.WithoutCancel(ctx), s.client, []string{s.leaseKey()}, s.consumer)
+	}()
+
+	// Entries earlier holders read but never acknowledged, e.g. before a
+	// crash, come first. They are claimed whatever their idle time, since
+	// no other consumer may be handling them now.
+	if _, err := s.reclaim(ctx, 0); err != nil {
+		return fmt.Errorf("redis: reclaim: %w", err)
+	}
+	reclaimed := time.Now()
+
+	// Start with the entries pending on this consumer, then move on to new
+	// ones.
+	id := "0"
+	for ctx.Err() == nil {
+		// A holder that lost the lease may still have read an entry before
+		// noticing; it is claimed once it has been idle for a lease.
+		if time.Since(reclaimed) >= s.lease {
+			claimed, err := s.reclaim(ctx, s.lease)
+			if err != nil && ctx.Err() == nil {
+				return fmt.Errorf("redis: reclaim: %w", err)
+			}
+			if claimed {
+				id = "0"
+			}
+			reclaimed = time.Now()
+		}
+
+		streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.consumer,
+			Streams:  []string{s.stream, id},
+			Count:    1,
+			Block:    redisBlock,
+		}).Result()
+		if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("redis: read: %w", err)
+		}
+
+		entries := streams[0].Messages
+		if len(entries) == 0 {
+			id = ">"
+			continue
+		}
+
+		entry := entries[0]
+		if err := retry(ctx, s.backoff, handle, redisMessage(entry.Values)); err != nil {
+			break
+		}
+		if err := s.client.XAck(context.WithoutCancel(ctx), s.stream, s.group, entry.ID).Err(); err != nil {
+			return fmt.Errorf("redis: ack: %w", err)
+		}
+	}
+
+	if errors.Is(context.Cause(ctx), errLeaseLost) {
+		return errLeaseLost
+	}
+	return nil
+}
+
+// keepLease renews the lease three times per lease period until ctx is done,
+// cancelling ctx with errLeaseLost when it fails to.
+func (s *redisSubscriber) keepLease(ctx context.Context, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(s.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		held, err := s.renew(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil || !held {
+			slog.ErrorContext(ctx, "Failed to renew the stream lease", "stream", s.stream, "consumer", s.consumer, "error", err)
+			cancel(errLeaseLost)
+			return
+		}
+	}
+}
+
+// reclaim moves the entries pending on any consumer for at least minIdle to
+// this one, which reads them next, and reports whether there were some.
+func (s *redisSubscriber) reclaim(ctx context.Context, minIdle time.Duration) (bool, error) {
+	claimed := false
+	start := "0-0"
+	for {
+		ids, next, err := s.client.XAutoClaimJustID(ctx, &redis.XAutoClaimArgs{
+			Stream:   s.stream,
+			Group:    s.group,
+			Consumer: s.consumer,
+			MinIdle:  minIdle,
+			Start:    start,
+			Count:    100,
+		}).Result()
+		if err != nil {
+			return claimed, err
+		}
+		claimed = claimed || len(ids) > 0
+		if next == "0-0" {
+			return claimed, nil
+		}
+		start = next
+	}
+}
+
+func redisMessage(values map[string]any) Message {
+	var msg Message
+	for field, value := range values {
+		s, _ := value.(string)
+		switch {
+		case field == keyField:
+			msg.Key = s
+		case field == valueField:
+			msg.Value = []byte(s)
+		case strings.HasPrefix(field, headerPrefix):
+			if msg.Headers == nil {
+				msg.Headers = map[string]string{}
+			}
+			msg.Headers[strings.TrimPrefix(field, headerPrefix)] = s
+		}
+	}
+	return msg
+}
+
+// Check reports the entries the group has yet to read plus those read but
+// not acknowledged as lag.
+func (s *redisSubscriber) Check(ctx context.Context) (Status, error) {
+	groups, err := s.client.XInfoGroups(ctx, s.stream).Result()
+	if err != nil {
+		return Status{}, fmt.Errorf("redis: %w", err)
+	}
+	for _, group := range groups {
+		if group.Name == s.group {
+			lag := group.Lag + group.Pending
+			return Status{Lag: &lag}, nil
+		}
+	}
+	return Status{}, fmt.Errorf("redis: group %s does not exist", s.group)
+}
+
+func (s *redisSubscriber) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,96 @@
+package transport_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/narunart-atise/skill-api-kafka/config"
+	"github.com/narunart-atise/skill-api-kafka/transport"
+	"github.com/narunart-atise/skill-api-kafka/transport/transporttest"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisConformance(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	// Each subscriber is a separate instance of the consumer.
+	var consumers atomic.Int64
+	redisConfig := func(stream string) config.Redis {
+		consumer := fmt.Sprintf("test-%d", consumers.Add(1))
+		return config.Redis{Addr: srv.Addr(), Stream: stream, Group: "conformance", Consumer: consumer, Lease: 300 * time.Millisecond}
+	}
+
+	transporttest.Run(t, transporttest.Backend{
+		Publisher: func(t *testing.T, stream string) transport.Publisher {
+			return transport.NewRedisPublisher(redisConfig(stream))
+		},
+		Subscriber: func(t *testing.T, stream string) transport.Subscriber {
+			s, err := transport.NewRedisSubscriber(redisConfig(stream), 50*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+	})
+}
+
+// TestRedisReclaimsFromDeadConsumer checks that an entry read by an instance
+// that died without acknowledging it or releasing the lease is handled once
+// the lease expires.
+func TestRedisReclaimsFromDeadConsumer(t *testing.T) {
+	srv := miniredis.RunT(t)
+	cfg := config.Redis{Addr: srv.Addr(), Stream: "skill", Group: "consumer", Consumer: "live", Lease: 300 * time.Millisecond}
+
+	p := transport.NewRedisPublisher(cfg)
+	defer p.Close()
+	if err := p.Publish(context.Background(), transport.Message{Key: "go", Value: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+	if err := client.XGroupCreateMkStream(ctx, cfg.Stream, cfg.Group, "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: cfg.Group, Consumer: "dead", Streams: []string{cfg.Stream, ">"}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set(ctx, cfg.Stream+":"+cfg.Group+":lease", "dead", cfg.Lease).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := transport.NewRedisSubscriber(cfg, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	delivered := make(chan string, 1)
+	go s.Subscribe(ctx, func(_ context.Context, msg transport.Message) error {
+		delivered <- string(msg.Value)
+		return nil
+	})
+
+	select {
+	case v := <-delivered:
+		t.Fatalf("Expected nothing while the dead consumer holds the lease, got %s", v)
+	case <-time.After(cfg.Lease):
+	}
+
+	srv.FastForward(cfg.Lease)
+	select {
+	case v := <-delivered:
+		if v != "1" {
+			t.Errorf("Expected the entry pending on the dead consumer, got %s", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the entry pending on the dead consumer to be reclaimed")
+	}
+}
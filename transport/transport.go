@@ -0,0 +1,110 @@
+// Package transport moves skill commands from the API to the consumer over a
+// configurable broker: Kafka, NATS JetStream or Redis Streams. Bus connects
+// the two inside a single process.
+package transport
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/config"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// Message is one command on the wire. Messages sharing a Key are delivered in
+// the order they were published.
+type Message struct {
+	Key     string
+	Value   []byte
+	Headers map[string]string
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// Handler processes one message. A message is acknowledged only after its
+// handler returns nil; otherwise it is delivered again after a backoff.
+type Handler func(ctx context.Context, msg Message) error
+
+type Subscriber interface {
+	// Subscribe hands messages to handle one at a time until ctx is
+	// cancelled. It then fetches no more, waits for the handler in progress
+	// and returns nil once the messages handled are acknowledged.
+	Subscribe(ctx context.Context, handle Handler) error
+	Close() error
+}
+
+// Checker is implemented by publishers and subscribers that can tell whether
+// they are ready to work, for readiness probes.
+type Checker interface {
+	// Check reports the state of the client, with an error when it cannot
+	// do its job, e.g. because the broker is unreachable.
+	Check(ctx context.Context) (Status, error)
+}
+
+// Status is what a Checker knows about its client.
+type Status struct {
+	// Partitions lists the Kafka partitions assigned to a subscriber.
+	Partitions []int32 `json:"partitions,omitempty"`
+	// Lag counts the messages waiting for a subscriber, when known.
+	Lag *int64 `json:"lag,omitempty"`
+	// PartitionLag breaks Lag down per Kafka partition.
+	PartitionLag map[int32]int64 `json:"partition_lag,omitempty"`
+}
+
+// Instrumented is implemented by publishers and subscribers whose client
+// keeps metrics of its own in a go-metrics registry, as sarama does.
+type Instrumented interface {
+	MetricRegistry() gometrics.Registry
+}
+
+// NewPublisher opens a publisher on the configured backend.
+func NewPublisher(cfg config.Transport, kafka config.Kafka) (Publisher, error) {
+	switch cfg.Backend {
+	case "kafka":
+		return NewKafkaPublisher(kafka)
+	case "nats":
+		return NewNATSPublisher(cfg.NATS)
+	case "redis":
+		return NewRedisPublisher(cfg.Redis), nil
+	default:
+		return nil, fmt.Errorf("transport: unknown backend %q", cfg.Backend)
+	}
+}
+
+// NewSubscriber opens a subscriber on the configured backend.
+func NewSubscriber(cfg config.Transport, kafka config.Kafka) (Subscriber, error) {
+	switch cfg.Backend {
+	case "kafka":
+		return NewKafkaSubscriber(kafka, cfg.RetryBackoff)
+	case "nats":
+		return NewNATSSubscriber(cfg.NATS, cfg.RetryBackoff)
+	case "redis":
+		return NewRedisSubscriber(cfg.Redis, cfg.RetryBackoff)
+	default:
+		return nil, fmt.Errorf("transport: unknown backend %q", cfg.Backend)
+	}
+}
+
+// retry calls handle until it succeeds, waiting backoff between attempts. It
+// returns the context error if ctx is cancelled first, in which case the
+// message must be left unacknowledged.
+func retry(ctx context.Context, backoff time.Duration, handle Handler, msg Message) error {
+	for {
+		err := handle(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		slog.WarnContext(ctx, "Failed to handle message, retrying", "key", msg.Key, "backoff", backoff, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
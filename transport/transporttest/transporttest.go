@@ -0,0 +1,419 @@
+// Package transporttest is a conformance suite every transport backend must
+// pass.
+package transporttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/narunart-atise/skill-api-kafka/transport"
+)
+
+// Backend opens clients on a stream named by the suite. Every test uses a
+// fresh name, and subscribers opened on the same name share their progress
+// like instances of one consumer. Each subscriber stands for a separate
+// instance, and several may run at once.
+type Backend struct {
+	Publisher  func(t *testing.T, stream string) transport.Publisher
+	Subscriber func(t *testing.T, stream string) transport.Subscriber
+}
+
+// Timeout bounds every wait in the suite. Kafka group joins take a few
+// seconds, so it is generous.
+var Timeout = 30 * time.Second
+
+// Run runs the suite against b.
+func Run(t *testing.T, b Backend) {
+	t.Run("PublishSubscribe", func(t *testing.T) { testPublishSubscribe(t, b) })
+	t.Run("Redelivery", func(t *testing.T) { testRedelivery(t, b) })
+	t.Run("Resume", func(t *testing.T) { testResume(t, b) })
+	t.Run("UnacknowledgedAfterStop", func(t *testing.T) { testUnacknowledged(t, b) })
+	t.Run("StopWhileHandling", func(t *testing.T) { testStopWhileHandling(t, b) })
+	t.Run("Failover", func(t *testing.T) { testFailover(t, b) })
+	t.Run("ConcurrentInstances", func(t *testing.T) { testConcurrentInstances(t, b) })
+	t.Run("Check", func(t *testing.T) { testCheck(t, b) })
+}
+
+var streams atomic.Int64
+
+func streamName() string {
+	return fmt.Sprintf("conformance-%d-%d", time.Now().UnixNano(), streams.Add(1))
+}
+
+func publish(t *testing.T, p transport.Publisher, messages ...transport.Message) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	for _, msg := range messages {
+		if err := p.Publish(ctx, msg); err != nil {
+			t.Fatalf("Publish error: %v", err)
+		}
+	}
+}
+
+// subscription runs Subscribe in the background and records every delivery.
+type subscription struct {
+	sub    transport.Subscriber
+	cancel context.CancelFunc
+	done   chan error
+
+	mu        sync.Mutex
+	delivered []transport.Message
+	notify    chan struct{}
+}
+
+// subscribe starts sub. fail, when not nil, decides whether a delivery
+// should be rejected.
+func subscribe(t *testing.T, sub transport.Subscriber, fail func(transport.Message) bool) *subscription {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &subscription{sub: sub, cancel: cancel, done: make(chan error, 1), notify: make(chan struct{}, 1)}
+
+	go func() {
+		s.done <- sub.Subscribe(ctx, func(ctx context.Context, msg transport.Message) error {
+			s.mu.Lock()
+			s.delivered = append(s.delivered, msg)
+			s.mu.Unlock()
+			select {
+			case s.notify <- struct{}{}:
+			default:
+			}
+
+			if fail != nil && fail(msg) {
+				return errors.New("rejected by test")
+			}
+			return nil
+		})
+	}()
+	t.Cleanup(func() { s.stop(t) })
+	return s
+}
+
+// wait blocks until at least n deliveries were recorded and returns them.
+func (s *subscription) wait(t *testing.T, n int) []transport.Message {
+	t.Helper()
+	deadline := time.After(Timeout)
+	for {
+		s.mu.Lock()
+		delivered := append([]transport.Message(nil), s.delivered...)
+		s.mu.Unlock()
+		if len(delivered) >= n {
+			return delivered
+		}
+
+		select {
+		case <-s.notify:
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("Expected %d deliveries, got %d", n, len(delivered))
+		}
+	}
+}
+
+// count returns the number of deliveries recorded so far.
+func (s *subscription) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.delivered)
+}
+
+// stop cancels Subscribe, waits for it to return and closes the subscriber.
+func (s *subscription) stop(t *testing.T) {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.cancel = nil
+
+	select {
+	case err := <-s.done:
+		if err != nil {
+			t.Errorf("Subscribe returned %v after cancel", err)
+		}
+	case <-time.After(Timeout):
+		t.Error("Subscribe did not return after cancel")
+	}
+	if err := s.sub.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+}
+
+func values(messages []transport.Message) []string {
+	out := make([]string, len(messages))
+	for i, msg := range messages {
+		out[i] = string(msg.Value)
+	}
+	return out
+}
+
+func testPublishSubscribe(t *testing.T, b Backend) {
+	stream := streamName()
+	p := b.Publisher(t, stream)
+	defer p.Close()
+
+	want := []transport.Message{
+		{Key: "go", Value: []byte(`{"action":"Insert"}`), Headers: map[string]string{"traceparent": "00-abc-def-01"}},
+		{Key: "rust", Value: []byte(`{"action":"Insert"}`)},
+		{Key: "go", Value: []byte(`{"action":"UpdateName"}`)},
+	}
+	publish(t, p, want...)
+
+	s := subscribe(t, b.Subscriber(t, stream), nil)
+	got := s.wait(t, len(want))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func testRedelivery(t *testing.T, b Backend) {
+	stream := streamName()
+	p := b.Publisher(t, stream)
+	defer p.Close()
+
+	publish(t, p, transport.Message{Key: "go", Value: []byte("1")}, transport.Message{Key: "go", Value: []byte("2")})
+
+	var failed atomic.Bool
+	s := subscribe(t, b.Subscriber(t, stream), func(msg transport.Message) bool {
+		return string(msg.Value) == "1" && !failed.Swap(true)
+	})
+
+	got := values(s.wait(t, 3))
+	if want := []string{"1", "1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected a failed message to be redelivered before the next, got %v", got)
+	}
+}
+
+func testResume(t *testing.T, b Backend) {
+	stream := streamName()
+	p := b.Publisher(t, stream)
+	defer p.Close()
+
+	publish(t, p, transport.Message{Key: "go", Value: []byte("1")})
+	first := subscribe(t, b.Subscriber(t, stream), nil)
+	first.wait(t, 1)
+	first.stop(t)
+
+	publish(t, p, transport.Message{Key: "go", Value: []byte("2")})
+	second := subscribe(t, b.Subscriber(t, stream), nil)
+	if got := values(second.wait(t, 1)); got[0] != "2" {
+		t.Errorf("Expected to resume after the acknowledged message, got %v", got)
+	}
+}
+
+func testUnacknowledged(t *testing.T, b Backend) {
+	stream := streamName()
+	p := b.Publisher(t, stream)
+	defer p.Close()
+
+	publish(t, p, transport.Message{Key: "go", Value: []byte("1")})
+	first := subscribe(t, b.Subscriber(t, stream), func(transport.Message) bool { return true })
+	first.wait(t, 1)
+	first.stop(t)
+
+	second := subscribe(t, b.Subscriber(t, stream), nil)
+	if got := values(second.wait(t, 1)); got[0] != "1" {
+		t.Errorf("Expected the unacknowledged message again, got %v", got)
+	}
+}
+
+func testStopWhileHandling(t *testing.T, b Backend) {
+	stream := streamName()
+	p := b.Publisher(t, stream)
+	defer p.Close()
+
+	publish(t, p,
+		transport.Message{Key: "go", Value: []byte("1")},
+		transport.Message{Key: "go", Value: []byte("2")},
+		transport.Message{Key: "go", Value: []byte("3")},
+	)
+
+	// The subscription is cancelled while the first message is handled, as
+	// on shutdown.
+	sub := b.Subscriber(t, stream)
+	ctx, cancel := context.WithCancel(context.Background())
+	var handled []string
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.Subscribe(ctx, func(_ context.Context, msg transport.Message) error {
+			handled = append(handled, string(msg.Value))
+			cancel()
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Subscribe returned %v after cancel", err)
+		}
+	case <-time.After(Timeout):
+		t.Fatal("Subscribe did not return after cancel")
+	}
+	if err := sub.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+	if want := []string{"1"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("Expected nothing fetched after cancel, got %v", handled)
+	}
+
+	next := subscribe(t, b.Subscriber(t, stream), nil)
+	if got := values(next.wait(t, 1)); got[0] != "2" {
+		t.Errorf("Expected the message handled during the stop to be acknowledged, got %v", got)
+	}
+}
+
+func testFailover(t *testing.T, b Backend) {
+	stream := streamName()
+	p := b.Publisher(t, stream)
+	defer p.Close()
+
+	// Both instances reject the message until the one it went to stops,
+	// leaving it unacknowledged.
+	var stopped atomic.Bool
+	fail := func(transport.Message) bool { return !stopped.Load() }
+	first := subscribe(t, b.Subscriber(t, stream), fail)
+	second := subscribe(t, b.Subscriber(t, stream), fail)
+	publish(t, p, transport.Message{Key: "go", Value: []byte("1")})
+
+	deadline := time.After(Timeout)
+	for first.count() == 0 && second.count() == 0 {
+		select {
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("Expected one instance to get the message")
+		}
+	}
+	holder, standby := first, second
+	if first.count() == 0 {
+		holder, standby = second, first
+	}
+	if standby.count() != 0 {
+		t.Fatal("Expected only one instance to get the message")
+	}
+	holder.stop(t)
+	stopped.Store(true)
+
+	publish(t, p, transport.Message{Key: "go", Value: []byte("2")})
+	if got := values(standby.wait(t, 2)); !reflect.DeepEqual(got[len(got)-2:], []string{"1", "2"}) {
+		t.Errorf("Expected the other instance to take over from the unacknowledged message, got %v", got)
+	}
+}
+
+func testConcurrentInstances(t *testing.T, b Backend) {
+	stream := streamName()
+	p := b.Publisher(t, stream)
+	defer p.Close()
+
+	// Messages for one key must be handled one after another and in order,
+	// whichever instance gets them.
+	var (
+		mu       sync.Mutex
+		handled  []string
+		inFlight atomic.Int32
+		overlap  atomic.Bool
+	)
+	handle := func(_ context.Context, msg transport.Message) error {
+		if inFlight.Add(1) > 1 {
+			overlap.Store(true)
+		}
+		defer inFlight.Add(-1)
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		handled = append(handled, string(msg.Value))
+		mu.Unlock()
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var running sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		sub := b.Subscriber(t, stream)
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			if err := sub.Subscribe(ctx, handle); err != nil {
+				t.Errorf("Subscribe error: %v", err)
+			}
+			if err := sub.Close(); err != nil {
+				t.Errorf("Close error: %v", err)
+			}
+		}()
+	}
+	defer func() {
+		cancel()
+		running.Wait()
+	}()
+
+	var want []string
+	for i := 1; i <= 10; i++ {
+		want = append(want, fmt.Sprint(i))
+		publish(t, p, transport.Message{Key: "go", Value: []byte(fmt.Sprint(i))})
+	}
+
+	deadline := time.Now().Add(Timeout)
+	for {
+		mu.Lock()
+		got := append([]string(nil), handled...)
+		mu.Unlock()
+		if len(got) >= len(want) {
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %v handled in order, got %v", want, got)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d messages handled, got %v", len(want), got)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if overlap.Load() {
+		t.Error("Expected messages for one key not to be handled concurrently")
+	}
+}
+
+func testCheck(t *testing.T, b Backend) {
+	stream := streamName()
+	p := b.Publisher(t, stream)
+	defer p.Close()
+
+	checker, ok := p.(transport.Checker)
+	if !ok {
+		t.Fatal("Expected the publisher to implement Checker")
+	}
+	if _, err := checker.Check(context.Background()); err != nil {
+		t.Errorf("Expected the publisher to be ready, got %v", err)
+	}
+
+	// The first message keeps failing, so both stay waiting. Some backends
+	// estimate lag, so it only has to cover them.
+	sub := b.Subscriber(t, stream)
+	s := subscribe(t, sub, func(transport.Message) bool { return true })
+	publish(t, p, transport.Message{Key: "go", Value: []byte("1")}, transport.Message{Key: "go", Value: []byte("2")})
+	s.wait(t, 1)
+
+	checker, ok = sub.(transport.Checker)
+	if !ok {
+		t.Fatal("Expected the subscriber to implement Checker")
+	}
+
+	var (
+		status transport.Status
+		err    error
+	)
+	deadline := time.Now().Add(Timeout)
+	for time.Now().Before(deadline) {
+		status, err = checker.Check(context.Background())
+		if err == nil && status.Lag != nil && *status.Lag >= 2 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Errorf("Expected the subscriber to be ready with a lag of at least 2, got %+v, %v", status, err)
+}
@@ -0,0 +1,3 @@
+module github.com/narunart-atise/skill-api-kafka/validate
+
+go 1.22.4
@@ -0,0 +1,190 @@
+// Package validate checks the fields of skill commands. The API checks them
+// before publishing a command and the consumer again before applying one, as
+// other producers may publish to the topic too.
+package validate
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MaxKeyLength         = 64
+	MaxNameLength        = 100
+	MaxDescriptionLength = 1000
+	MaxLogoURLLength     = 2048
+	// MaxLogoDataLength bounds a logo inlined as a data URI.
+	MaxLogoDataLength = 64 << 10
+	MaxTags           = 20
+	MaxTagLength      = 32
+)
+
+var (
+	slug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	// Tags may also hold the +, # and . of names such as c++, c# or .net.
+	tag = regexp.MustCompile(`^[a-z0-9+#.][a-z0-9+#.-]*$`)
+)
+
+// FieldError tells what is wrong with one field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors lists every invalid field of a command.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return "invalid skill: " + strings.Join(msgs, "; ")
+}
+
+func (e *Errors) add(field string, err error) {
+	if err != nil {
+		*e = append(*e, FieldError{Field: field, Message: err.Error()})
+	}
+}
+
+// Skill holds the fields of a skill command.
+type Skill struct {
+	Key         string
+	Name        string
+	Description string
+	Logo        string
+	Tags        []string
+}
+
+// Command checks the fields action reads from s, returning Errors when some
+// are invalid. The key must be a slug when a skill is inserted; other
+// actions only need one, so skills stored before these rules stay editable.
+func Command(action string, s Skill) error {
+	var errs Errors
+	if action == "Insert" {
+		errs.add("key", Key(s.Key))
+	} else if s.Key == "" {
+		errs.add("key", errors.New("is required"))
+	}
+
+	switch action {
+	case "Insert", "Update":
+		errs.add("name", Name(s.Name))
+		errs.add("description", Description(s.Description))
+		errs.add("logo", Logo(s.Logo))
+		errs = append(errs, Tags(s.Tags)...)
+	case "UpdateName":
+		errs.add("name", Name(s.Name))
+	case "UpdateDescription":
+		errs.add("description", Description(s.Description))
+	case "UpdateLogo":
+		errs.add("logo", Logo(s.Logo))
+	case "UpdateTags":
+		// Unlike an empty list, no list at all is a mistake: it would clear
+		// the tags.
+		if s.Tags == nil {
+			errs.add("tags", errors.New("is required"))
+		}
+		errs = append(errs, Tags(s.Tags)...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Key checks that key is a slug: lowercase letters and digits in words
+// joined by single hyphens.
+func Key(key string) error {
+	switch {
+	case key == "":
+		return errors.New("is required")
+	case len(key) > MaxKeyLength:
+		return fmt.Errorf("must be at most %d characters", MaxKeyLength)
+	case !slug.MatchString(key):
+		return errors.New("must be lowercase letters and digits separated by single hyphens")
+	}
+	return nil
+}
+
+func Name(name string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return errors.New("is required")
+	case utf8.RuneCountInString(name) > MaxNameLength:
+		return fmt.Errorf("must be at most %d characters", MaxNameLength)
+	}
+	return nil
+}
+
+func Description(description string) error {
+	if utf8.RuneCountInString(description) > MaxDescriptionLength {
+		return fmt.Errorf("must be at most %d characters", MaxDescriptionLength)
+	}
+	return nil
+}
+
+// Logo checks that logo, when set, is an http or https URL or an image data
+// URI.
+func Logo(logo string) error {
+	if logo == "" {
+		return nil
+	}
+
+	if rest, ok := strings.CutPrefix(logo, "data:"); ok {
+		if len(logo) > MaxLogoDataLength {
+			return fmt.Errorf("data URI must be at most %d bytes", MaxLogoDataLength)
+		}
+		meta, data, ok := strings.Cut(rest, ",")
+		if !ok || !strings.HasPrefix(meta, "image/") {
+			return errors.New("data URI must hold an image")
+		}
+		if strings.HasSuffix(meta, ";base64") {
+			if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+				return errors.New("data URI is not valid base64")
+			}
+		}
+		return nil
+	}
+
+	if len(logo) > MaxLogoURLLength {
+		return fmt.Errorf("must be at most %d characters", MaxLogoURLLength)
+	}
+	if u, err := url.Parse(logo); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("must be an http or https URL or a data URI")
+	}
+	return nil
+}
+
+// Tags checks the number of tags and each of them, reporting a bad tag
+// under its index.
+func Tags(tags []string) Errors {
+	var errs Errors
+	if len(tags) > MaxTags {
+		errs.add("tags", fmt.Errorf("must be at most %d", MaxTags))
+	}
+
+	seen := map[string]bool{}
+	for i, t := range tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		switch {
+		case t == "":
+			errs.add(field, errors.New("must not be empty"))
+		case len(t) > MaxTagLength:
+			errs.add(field, fmt.Errorf("must be at most %d characters", MaxTagLength))
+		case !tag.MatchString(t):
+			errs.add(field, errors.New("must be lowercase letters, digits and + # . -"))
+		case seen[t]:
+			errs.add(field, fmt.Errorf("repeats %q", t))
+		}
+		seen[t] = true
+	}
+	return errs
+}
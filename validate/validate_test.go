@@ -0,0 +1,76 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func fields(err error) []string {
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	var names []string
+	for _, fe := range errs {
+		names = append(names, fe.Field)
+	}
+	return names
+}
+
+func TestCommand(t *testing.T) {
+	valid := Skill{
+		Key:         "go",
+		Name:        "Go",
+		Description: "Go is an open source programming language.",
+		Logo:        "https://go.dev/images/go-logo-blue.svg",
+		Tags:        []string{"go", "golang", "c++", ".net"},
+	}
+	many := make([]string, MaxTags+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("t%d", i)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		action string
+		skill  Skill
+		want   []string
+	}{
+		{"valid insert", "Insert", valid, nil},
+		{"insert without anything", "Insert", Skill{}, []string{"key", "name"}},
+		{"insert with bad key", "Insert", Skill{Key: "Go Lang", Name: "Go"}, []string{"key"}},
+		{"insert with long key", "Insert", Skill{Key: strings.Repeat("a", MaxKeyLength+1), Name: "Go"}, []string{"key"}},
+		{"update keeps an old key", "Update", Skill{Key: "Go_Lang", Name: "Go"}, nil},
+		{"blank name", "UpdateName", Skill{Key: "go", Name: "  "}, []string{"name"}},
+		{"long name", "UpdateName", Skill{Key: "go", Name: strings.Repeat("é", MaxNameLength+1)}, []string{"name"}},
+		{"name only checked", "UpdateName", Skill{Key: "go", Name: "Go", Logo: "nope"}, nil},
+		{"empty description", "UpdateDescription", Skill{Key: "go"}, nil},
+		{"long description", "UpdateDescription", Skill{Key: "go", Description: strings.Repeat("a", MaxDescriptionLength+1)}, []string{"description"}},
+		{"relative logo", "UpdateLogo", Skill{Key: "go", Logo: "go-logo.png"}, []string{"logo"}},
+		{"ftp logo", "UpdateLogo", Skill{Key: "go", Logo: "ftp://example.com/go.png"}, []string{"logo"}},
+		{"data logo", "UpdateLogo", Skill{Key: "go", Logo: "data:image/png;base64,iVBORw0KGgo="}, nil},
+		{"bad base64 logo", "UpdateLogo", Skill{Key: "go", Logo: "data:image/png;base64,!!"}, []string{"logo"}},
+		{"non-image data logo", "UpdateLogo", Skill{Key: "go", Logo: "data:text/html,<script>"}, []string{"logo"}},
+		{"no tags", "UpdateTags", Skill{Key: "go"}, []string{"tags"}},
+		{"cleared tags", "UpdateTags", Skill{Key: "go", Tags: []string{}}, nil},
+		{"bad tags", "UpdateTags", Skill{Key: "go", Tags: []string{"go", "Go Lang", "", "go"}}, []string{"tags[1]", "tags[2]", "tags[3]"}},
+		{"too many tags", "UpdateTags", Skill{Key: "go", Tags: many}, []string{"tags"}},
+		{"delete", "DeleteSkill", Skill{Key: "go"}, nil},
+		{"delete without key", "DeleteSkill", Skill{}, []string{"key"}},
+	} {
+		got := fields(Command(tc.action, tc.skill))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: expected errors on %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	err := Command("Insert", Skill{Key: "go"})
+	if err == nil || err.Error() != "invalid skill: name is required" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}